@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/username/envtool/pkg/envfile"
+)
+
+var checkStrict bool
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check [file...]",
+	Short: "Report problems in .env files",
+	Long: `Parse .env files and report problems such as malformed lines,
+unterminated quotes, invalid key names and duplicate keys.
+
+If a schema file exists, .env.schema unless --schema names another one,
+the merged variables are checked against it too: required variables that
+are not set are errors, as are values not of the variable's type, while
+variables the schema does not describe are warnings. Secret references and
+$(command) substitutions are not type-checked.
+
+Problems are printed one per line as file:line:column: severity: message.
+The command exits with a non-zero status if any errors are found, or any
+warnings when --strict is set, so it can be used in pre-commit hooks.
+Without arguments, the files envtool env would load are checked.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		paths := args
+		if len(paths) == 0 {
+			configured, err := envFilePaths()
+			if err != nil {
+				return err
+			}
+			paths = configured
+		}
+
+		parser := &envfile.DefaultParser{ReadDocument: loadKeyring().ReadDocument}
+		errors, warnings := 0, 0
+		report := func(diags envfile.Diagnostics) {
+			for _, d := range diags {
+				fmt.Fprintln(cmd.ErrOrStderr(), d)
+				if d.Severity == envfile.SeverityError {
+					errors++
+				} else {
+					warnings++
+				}
+			}
+		}
+		for _, path := range paths {
+			diags, err := parser.Check(path)
+			if os.IsNotExist(err) && len(args) == 0 {
+				// Configured layers are optional
+				continue
+			} else if err != nil {
+				return err
+			}
+			report(diags)
+		}
+
+		// Check the merged variables against the schema; syntax problems
+		// were reported above
+		s, err := loadSchema()
+		if err != nil {
+			return err
+		}
+		if s != nil {
+			merged, err := parser.ParseFiles(paths)
+			if _, ok := err.(envfile.Diagnostics); !ok && err != nil {
+				return err
+			}
+			report(s.Check(merged, uncheckedValue))
+		}
+
+		if errors > 0 || (checkStrict && warnings > 0) {
+			return fmt.Errorf("found %d error(s) and %d warning(s)", errors, warnings)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	checkCmd.Flags().BoolVar(&checkStrict, "strict", false, "Exit with a non-zero status on warnings too")
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCmd(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-check-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	good := filepath.Join(tempDir, "good.env")
+	dup := filepath.Join(tempDir, "dup.env")
+	bad := filepath.Join(tempDir, "bad.env")
+	assert.NoError(t, ioutil.WriteFile(good, []byte("FOO=bar\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(dup, []byte("FOO=1\nFOO=2\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(bad, []byte("FOO=bar\nBAD LINE\n"), 0644))
+
+	origStrict := checkStrict
+	defer func() { checkStrict = origStrict }()
+
+	testCases := []struct {
+		name     string
+		args     []string
+		strict   bool
+		wantErr  bool
+		expected string
+	}{
+		{
+			name:     "Clean file",
+			args:     []string{good},
+			expected: "",
+		},
+		{
+			name:     "Warnings pass by default",
+			args:     []string{dup},
+			expected: dup + ":2:1: warning: duplicate key \"FOO\", first defined on line 1\n",
+		},
+		{
+			name:     "Warnings fail in strict mode",
+			args:     []string{dup},
+			strict:   true,
+			wantErr:  true,
+			expected: dup + ":2:1: warning: duplicate key \"FOO\", first defined on line 1\n",
+		},
+		{
+			name:     "Errors fail",
+			args:     []string{good, bad},
+			wantErr:  true,
+			expected: bad + ":2:5: error: expected '=' after \"BAD\"\n",
+		},
+		{
+			name:    "Missing file",
+			args:    []string{filepath.Join(tempDir, "missing.env")},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			checkStrict = tc.strict
+			checkCmd.SetErr(&stderr)
+			defer checkCmd.SetErr(nil)
+
+			err := checkCmd.RunE(checkCmd, tc.args)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, stderr.String())
+		})
+	}
+}
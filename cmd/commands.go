@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/username/envtool/pkg/cache"
+	"github.com/username/envtool/pkg/envfile"
+	"github.com/username/envtool/pkg/resolve"
+	"github.com/username/envtool/pkg/trust"
+)
+
+// Key for the files $(...) substitutions watch, so that a change to one of
+// them reloads the .env files at the next prompt
+const WatchKey = "ENVTOOL_WATCH"
+
+const (
+	defaultCommandTimeout = 5 * time.Second
+	defaultCommandTTL     = time.Minute
+)
+
+// commandEnv lists the variables commands get from the environment envtool
+// runs in; the commands.env setting adds to it
+var commandEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "LANG", "LC_ALL", "LC_CTYPE", "TMPDIR", "TZ",
+}
+
+// commandRunner runs the $(...) substitutions and ref+cmd:// references of
+// trusted .env files, with a timeout and a restricted environment. The
+// output of substitutions is cached on disk: that of a command with watch
+// files is reused for as long as they do not change, that of other
+// commands for the commands.ttl setting. References usually produce
+// secrets, so their output is only kept in memory.
+type commandRunner struct {
+	store   *trust.Store
+	cache   *cache.Store
+	timeout time.Duration
+	ttl     time.Duration
+	env     []string
+	force   bool
+	watched map[string]bool
+}
+
+// newCommandRunner returns a runner for the commands of files trusted by
+// store, or nil unless the commands.enabled setting turns them on. With
+// force, cached outputs are ignored.
+func newCommandRunner(store *trust.Store, force bool) (*commandRunner, error) {
+	if !viper.GetBool("commands.enabled") {
+		return nil, nil
+	}
+	path, err := commandCachePath()
+	if err != nil {
+		return nil, err
+	}
+	c, err := cache.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &commandRunner{
+		store:   store,
+		cache:   c,
+		timeout: defaultCommandTimeout,
+		ttl:     defaultCommandTTL,
+		force:   force,
+		watched: map[string]bool{},
+	}
+	if viper.IsSet("commands.timeout") {
+		r.timeout = viper.GetDuration("commands.timeout")
+	}
+	if viper.IsSet("commands.ttl") {
+		r.ttl = viper.GetDuration("commands.ttl")
+	}
+	for _, name := range append(commandEnv, viper.GetStringSlice("commands.env")...) {
+		if value, ok := os.LookupEnv(name); ok {
+			r.env = append(r.env, name+"="+value)
+		}
+	}
+	return r, nil
+}
+
+// Run returns the output of c
+func (r *commandRunner) Run(c envfile.Command) (string, error) {
+	return r.run(context.Background(), c, false)
+}
+
+// Resolve runs the command of a ref+cmd:// reference, making the runner a
+// resolve.Provider
+func (r *commandRunner) Resolve(ctx context.Context, ref resolve.Ref) (string, error) {
+	return r.run(ctx, envfile.Command{Text: ref.Target, File: ref.File, Dir: ref.Dir}, true)
+}
+
+// run returns the output of c. With transient, the output is cached in
+// memory only.
+func (r *commandRunner) run(ctx context.Context, c envfile.Command, transient bool) (string, error) {
+	// Trust was checked before the file was read, but the file may have
+	// changed since
+	status, err := r.store.Check(c.File)
+	if err != nil {
+		return "", err
+	}
+	if status != trust.Trusted {
+		return "", fmt.Errorf("%s is not trusted to run commands", c.File)
+	}
+
+	maxAge := r.ttl
+	watch := make([]string, 0, len(c.Watch))
+	for _, path := range c.Watch {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.Dir, path)
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		watch = append(watch, path)
+		r.watched[path] = true
+	}
+	if len(watch) > 0 {
+		maxAge = cache.MaxAge
+	}
+	dir, err := filepath.Abs(c.Dir)
+	if err != nil {
+		return "", err
+	}
+	key := cache.Key(dir, c.Text, strings.Join(r.env, "\x00"), envfile.Fingerprint(watch))
+	if transient {
+		key = cache.Key("ref", key)
+	}
+	if !r.force {
+		if output, ok := r.cache.Get(key, maxAge); ok {
+			return output, nil
+		}
+	}
+
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	output, err := resolve.Command{Env: r.env}.Resolve(ctx, resolve.Ref{Scheme: "cmd", Target: c.Text, Dir: c.Dir})
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("%s: timed out after %s", c.Text, r.timeout)
+		}
+		return "", err
+	}
+	if transient {
+		r.cache.PutTransient(key, output)
+	} else {
+		r.cache.Put(key, output)
+	}
+	return output, nil
+}
+
+// Watched returns the absolute paths of the files the commands that ran
+// watch, joined like $PATH, or "" if there are none
+func (r *commandRunner) Watched() string {
+	paths := make([]string, 0, len(r.watched))
+	for path := range r.watched {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return strings.Join(paths, string(os.PathListSeparator))
+}
+
+// Save writes the cached outputs back to disk
+func (r *commandRunner) Save() error {
+	return r.cache.Save()
+}
+
+// commandCachePath returns the command cache file: the command-cache
+// setting, or $ENVTOOL_COMMAND_CACHE, or the default location
+func commandCachePath() (string, error) {
+	if path := viper.GetString("command-cache"); path != "" {
+		return path, nil
+	}
+	return cache.DefaultPath()
+}
+
+// watchedFiles splits the value of ENVTOOL_WATCH
+func watchedFiles(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return filepath.SplitList(value)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/username/envtool/pkg/envfile"
+)
+
+func TestEnvCmd_Commands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	os.Setenv("ENVTOOL_TEST_HIDDEN", "leaked")
+	defer func() {
+		os.Unsetenv("ENVTOOL_TEST_HIDDEN")
+		os.Unsetenv(WatchKey)
+		viper.Set("commands.enabled", nil)
+		viper.Set("commands.timeout", nil)
+	}()
+
+	content := "SHA=\"$(echo sha >> calls; cat head)\" # watch: head\n" +
+		"TTL=\"$(echo ttl >> calls; echo ${HOME:+home}-${ENVTOOL_TEST_HIDDEN:-hidden})\"\n" +
+		"SLOW=$(sleep 5)\n"
+	inTempProject(t, content, func(dir string) {
+		head := filepath.Join(dir, "head")
+		assert.NoError(t, ioutil.WriteFile(head, []byte("v1\n"), 0644))
+		calls := func(name string) int {
+			data, _ := ioutil.ReadFile(filepath.Join(dir, "calls"))
+			return strings.Count(string(data), name)
+		}
+
+		// Commands are not run unless enabled
+		var out bytes.Buffer
+		envCmd.SetOut(&out)
+		assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		assert.Equal(t, "$(sleep 5)", exported(out.String(), "SLOW"))
+		assert.Equal(t, 0, calls("sha"))
+
+		viper.Set("commands.enabled", true)
+		viper.Set("commands.timeout", "200ms")
+		out.Reset()
+		stderr := captureStderr(t, func() {
+			assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		})
+		output := out.String()
+		assert.Equal(t, "v1", exported(output, "SHA"))
+		assert.Equal(t, "home-hidden", exported(output, "TTL"))
+		assert.NotContains(t, output, "SLOW")
+		assert.Contains(t, stderr, "cannot expand SLOW: sleep 5: timed out after 200ms")
+		assert.Equal(t, head, exported(output, WatchKey))
+		assert.Equal(t, 1, calls("sha"))
+		assert.Equal(t, 1, calls("ttl"))
+
+		// Nothing is run again while the watched file does not change
+		os.Setenv(WatchKey, exported(output, WatchKey))
+		os.Setenv(FingerprintKey, exported(output, FingerprintKey))
+		out.Reset()
+		assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		assert.Empty(t, out.String())
+
+		// Changing it reloads the variables, taking the other command's
+		// output from the cache
+		assert.NoError(t, ioutil.WriteFile(head, []byte("v2 changed\n"), 0644))
+		out.Reset()
+		captureStderr(t, func() {
+			assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		})
+		assert.Equal(t, "v2 changed", exported(out.String(), "SHA"))
+		assert.Equal(t, "home-hidden", exported(out.String(), "TTL"))
+		assert.Equal(t, 2, calls("sha"))
+		assert.Equal(t, 1, calls("ttl"))
+		assert.NotContains(t, out.String(), WatchKey)
+
+		// Commands only run from trusted files
+		store, err := loadTrustStore()
+		assert.NoError(t, err)
+		runner, err := newCommandRunner(store, false)
+		assert.NoError(t, err)
+		untrusted := filepath.Join(dir, ".env.local")
+		assert.NoError(t, ioutil.WriteFile(untrusted, []byte("X=$(echo sha >> calls)\n"), 0644))
+		_, err = runner.Run(envfile.Command{Text: "echo sha >> calls", File: untrusted, Dir: dir})
+		assert.EqualError(t, err, untrusted+" is not trusted to run commands")
+		assert.Equal(t, 2, calls("sha"))
+	})
+}
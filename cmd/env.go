@@ -1,28 +1,98 @@
 package cmd
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
+	"text/tabwriter"
+	"time"
 
-	"github.com/alessio/shellescape"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/username/envtool/pkg/emitter"
 	"github.com/username/envtool/pkg/envfile"
 )
 
 const (
 	// Key for tracking managed environment variables
 	ManagedEnvVarsKey = "ENVTOOL_MANAGED_ENV_VARS"
+	// Key for saving the values managed variables had before envtool
+	SavedEnvVarsKey = "ENVTOOL_SAVED_ENV"
+	// Key for the fingerprint of the files the last run loaded
+	FingerprintKey = "ENVTOOL_FINGERPRINT"
+	// Key for remembering which secret references were resolved, and when
+	ResolvedKey = "ENVTOOL_RESOLVED"
+)
+
+var (
+	envExplain bool
+	envForce   bool
 )
 
 // envCmd represents the env command
 var envCmd = &cobra.Command{
-	Use:   "env [shell]",
+	Use:   "env [bash|zsh|fish|pwsh|nu]",
 	Short: "Generate shell commands to set environment variables",
 	Long: `Generate shell commands to set environment variables from a .env file.
-The output should be evaluated by the shell to apply the changes.`,
+The output should be evaluated by the shell to apply the changes.
+
+Several files can be layered by repeating --env-file, or with the layered
+preset, which loads .env, .env.local, .env.$ENVTOOL_ENV and
+.env.$ENVTOOL_ENV.local. Later files override earlier ones and missing
+files are skipped. With --walk, .env files in parent directories are
+loaded too, and the nearest file wins. Use --explain to list which file
+set each variable instead of printing shell commands.
+
+Files are only loaded once they have been trusted with envtool allow, and
+only for as long as their content does not change. Untrusted files are
+skipped with a notice on stderr.
+
+Each file is followed by its encrypted variant FILE.enc, if it exists, and
+ENC[age:...] values are decrypted too, with the age identity file. The
+plaintext is only ever held in memory.
+
+Variables such as LD_PRELOAD, LD_LIBRARY_PATH, BASH_ENV, PROMPT_COMMAND,
+IFS and envtool's own ENVTOOL_* are never set; a warning names the file
+and line that tried. The policy.deny, policy.allow and policy.name-pattern
+settings adjust which names are accepted.
+
+Variables that were already set before envtool overrode them are saved
+in ENVTOOL_SAVED_ENV and restored once envtool stops managing them.
+
+Values of the form ref+<provider>://<target> are references to secrets,
+which are resolved when the files are loaded: ref+file://PATH reads a file,
+ref+env://NAME takes a variable from the environment, ref+cmd://COMMAND
+runs a shell command in the .env file's directory, under the same rules as
+$(command) below, and
+ref+vault://PATH#FIELD reads a Vault KV secret from $VAULT_ADDR. Each
+provider has a timeout; values from commands and Vault are reused for a
+while, which ENVTOOL_RESOLVED keeps track of. The resolve.<provider>.timeout
+and resolve.<provider>.ttl settings adjust both.
+
+Each run also exports ENVTOOL_FINGERPRINT, a digest of the current
+directory and the path, modification time, size and inode of every file it
+may load. When nothing has changed since the last run, envtool env prints
+nothing without reading any file. Use --force to reload anyway, resolving
+every reference again.
+
+With --schema-defaults, or the schema.defaults setting, variables that no
+file sets take the default from the schema file, .env.schema unless
+--schema names another one. The schema must be allowed like .env files.
+
+With the commands.enabled setting, $(command) in unquoted and double-quoted
+values is replaced by the output of command, run by /bin/sh in the .env
+file's directory. Commands only run from trusted files, within
+commands.timeout (5s by default), and only see PATH, HOME, USER, LOGNAME,
+SHELL, the locale, TMPDIR, TZ and the variables listed in commands.env.
+Their output is cached in ~/.cache/envtool/commands.json for commands.ttl
+(1m by default). An entry can instead name the files its command depends
+on, as in SHA=$(git rev-parse HEAD) # watch: .git/HEAD; the output is then
+reused until one of them changes, and changing one reloads the variables
+at the next prompt.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Get shell type (bash, zsh, etc.) if provided
@@ -30,72 +100,240 @@ The output should be evaluated by the shell to apply the changes.`,
 		if len(args) > 0 {
 			shellType = args[0]
 		}
+		em, err := emitter.ForShell(shellType)
+		if err != nil {
+			return err
+		}
 		
-		// Get path to .env file
-		envFilePath := viper.GetString("env-file")
-		
-		// Parse .env file
-		parser := &envfile.DefaultParser{}
-		envVars, err := parser.Parse(envFilePath)
+		// Skip all work when no file changed since the last run. The files
+		// are looked at before they are read, so that a change made while
+		// reading them shows up in the next fingerprint.
+		fingerprint := ""
+		if !envExplain {
+			fingerprint = currentFingerprint(shellType)
+			if !envForce && fingerprint != "" && fingerprint == os.Getenv(FingerprintKey) {
+				return nil
+			}
+		}
+
+		// Load the .env files. References are resolved reusing values
+		// resolved by earlier runs while they are fresh.
+		cached := ""
+		if !envForce {
+			cached = os.Getenv(ResolvedKey)
+		}
+		cache := loadResolvedCache(cached, os.LookupEnv, time.Now)
+		merged, watch, err := loadEnvFiles(loadOptions{
+			evaluate: !envExplain,
+			force:    envForce,
+			cache:    cache,
+			check:    validKeyCheck(em, shellType),
+		}, os.Stderr)
 		if err != nil {
-			// Just return empty if file doesn't exist or can't be parsed
+			fmt.Fprintln(os.Stderr, "envtool:", err)
+			return nil
+		}
+
+		if envExplain {
+			printOrigins(cmd.OutOrStdout(), merged)
 			return nil
 		}
+
+		// Watch the files commands depend on from now on
+		if fingerprint != "" && watch != os.Getenv(WatchKey) {
+			fingerprint = fingerprintWith(shellType, watchedFiles(watch))
+		}
 		
-		// Get currently managed env vars
-		managedEnvVarsStr := os.Getenv(ManagedEnvVarsKey)
-		managedEnvVars := []string{}
-		if managedEnvVarsStr != "" {
-			managedEnvVars = strings.Split(managedEnvVarsStr, ",")
+		// Get currently managed env vars and their saved original values
+		state, err := loadEnvState(os.Getenv)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "envtool:", err)
 		}
 		
 		// Generate export commands
-		output := generateExportCommands(managedEnvVars, envVars, shellType)
+		meta := map[string]string{FingerprintKey: fingerprint, ResolvedKey: cache.Encode(), WatchKey: watch}
+		output := generateExportCommands(state, merged.Values, meta, os.LookupEnv, em)
 		
 		// Print to stdout (will be captured by eval in the shell)
-		fmt.Print(output)
+		fmt.Fprint(cmd.OutOrStdout(), output)
 		return nil
 	},
 }
 
-// generateExportCommands generates shell commands to export/unset env vars
-func generateExportCommands(currentVars []string, newVars map[string]string, shellType string) string {
-	commands := []string{}
+// envState is what envtool remembers in the shell environment between runs
+type envState struct {
+	// Managed lists the variables currently set by envtool
+	Managed []string
+	// Saved holds the values variables had before envtool overrode them
+	Saved map[string]string
+}
+
+// loadEnvState reads the managed variable list and saved values
+func loadEnvState(getenv func(string) string) (envState, error) {
+	state := envState{Managed: []string{}, Saved: map[string]string{}}
+	if managed := getenv(ManagedEnvVarsKey); managed != "" {
+		state.Managed = strings.Split(managed, ",")
+	}
+	if saved := getenv(SavedEnvVarsKey); saved != "" {
+		data, err := base64.RawURLEncoding.DecodeString(saved)
+		if err == nil {
+			err = json.Unmarshal(data, &state.Saved)
+		}
+		if err != nil {
+			return state, fmt.Errorf("ignoring malformed %s: %w", SavedEnvVarsKey, err)
+		}
+	}
+	return state, nil
+}
+
+// encodeSaved encodes saved values so that they need no shell quoting
+func encodeSaved(saved map[string]string) string {
+	data, _ := json.Marshal(saved)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// generateExportCommands generates shell commands to export/unset env vars.
+// Variables that leave the managed set are restored to their saved value,
+// or unset if they did not exist before envtool set them. Each meta
+// variable, such as the fingerprint, is exported when its value differs
+// from the current one, and unset when its value is empty.
+func generateExportCommands(state envState, newVars map[string]string, meta map[string]string, lookupEnv func(string) (string, bool), em emitter.Emitter) string {
+	changes := []emitter.Change{}
+	saved := make(map[string]string, len(state.Saved))
+	for key, value := range state.Saved {
+		saved[key] = value
+	}
+	managed := make(map[string]bool, len(state.Managed))
+	for _, key := range state.Managed {
+		managed[key] = true
+	}
 	
-	// Generate unset commands for variables that are no longer present
-	for _, key := range currentVars {
+	// Restore or unset variables that are no longer present
+	for _, key := range state.Managed {
 		if _, exists := newVars[key]; !exists && key != "" {
-			commands = append(commands, fmt.Sprintf("unset %s", key))
+			if value, ok := saved[key]; ok {
+				changes = append(changes, emitter.Change{Key: key, Value: value})
+				delete(saved, key)
+			} else {
+				changes = append(changes, emitter.Change{Key: key, Unset: true})
+			}
 		}
 	}
 	
-	// Generate export commands for new variables
+	// Generate export commands for new variables, leaving out names the
+	// shell cannot take
 	newVarKeys := make([]string, 0, len(newVars))
 	for key := range newVars {
-		newVarKeys = append(newVarKeys, key)
+		if em.ValidKey(key) {
+			newVarKeys = append(newVarKeys, key)
+		}
 	}
 	
 	// Sort keys for consistent output
 	sort.Strings(newVarKeys)
 	
 	for _, key := range newVarKeys {
-		value := newVars[key]
-		// Quote value if not already quoted
-		if !strings.HasPrefix(value, "'") {
-			value = shellescape.Quote(value)
+		// Save the value a variable had before envtool first overrides it
+		if !managed[key] {
+			if value, ok := lookupEnv(key); ok {
+				saved[key] = value
+			}
 		}
-		commands = append(commands, fmt.Sprintf("export %s=%s", key, value))
+		changes = append(changes, emitter.Change{Key: key, Value: newVars[key]})
 	}
 	
 	// Add command to update the managed vars list
 	if len(newVarKeys) > 0 {
 		newManagedVars := strings.Join(newVarKeys, ",")
-		commands = append(commands, fmt.Sprintf("export %s=%s", ManagedEnvVarsKey, newManagedVars))
+		changes = append(changes, emitter.Change{Key: ManagedEnvVarsKey, Value: newManagedVars})
+	} else if len(state.Managed) > 0 {
+		changes = append(changes, emitter.Change{Key: ManagedEnvVarsKey, Unset: true})
 	}
 	
-	return strings.Join(commands, "\n")
+	// Add command to update the saved values
+	if len(saved) > 0 {
+		changes = append(changes, emitter.Change{Key: SavedEnvVarsKey, Value: encodeSaved(saved)})
+	} else if len(state.Saved) > 0 {
+		changes = append(changes, emitter.Change{Key: SavedEnvVarsKey, Unset: true})
+	}
+
+	// Remember what was loaded so that the next run can skip the work
+	metaKeys := make([]string, 0, len(meta))
+	for key := range meta {
+		metaKeys = append(metaKeys, key)
+	}
+	sort.Strings(metaKeys)
+	for _, key := range metaKeys {
+		current, ok := lookupEnv(key)
+		if value := meta[key]; value != "" && value != current {
+			changes = append(changes, emitter.Change{Key: key, Value: value})
+		} else if value == "" && ok {
+			changes = append(changes, emitter.Change{Key: key, Unset: true})
+		}
+	}
+	
+	return em.Emit(changes)
+}
+
+// currentFingerprint summarizes everything the output of envtool env
+// depends on apart from the process environment: the working directory,
+// the shell type, the config file, the trust database, the age identity,
+// the files that may be loaded and the files their commands watch. It
+// returns "" if the files cannot be determined.
+func currentFingerprint(shellType string) string {
+	return fingerprintWith(shellType, watchedFiles(os.Getenv(WatchKey)))
+}
+
+// fingerprintWith is currentFingerprint with the given watched files
+func fingerprintWith(shellType string, watch []string) string {
+	paths, err := envFileCandidates()
+	if err != nil {
+		return ""
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	if configFile := viper.ConfigFileUsed(); configFile != "" {
+		paths = append(paths, configFile)
+	}
+	// Allowing or denying a file must take effect at the next prompt
+	if trustDB, err := trustStorePath(); err == nil {
+		paths = append(paths, trustDB)
+	}
+	// So must a new identity to decrypt with
+	if identity, err := identityPath(); err == nil {
+		paths = append(paths, identity)
+	}
+	// And a change to the defaults of the schema
+	if viper.GetBool("schema.defaults") {
+		paths = append(paths, schemaPath())
+	}
+	paths = append(paths, watch...)
+	return envfile.Fingerprint(paths, cwd, shellType)
+}
+
+// printOrigins writes each merged variable with the file and line that set it
+func printOrigins(out io.Writer, merged *envfile.Merged) {
+	keys := make([]string, 0, len(merged.Origins))
+	for key := range merged.Origins {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, key := range keys {
+		origin := merged.Origins[key]
+		fmt.Fprintf(w, "%s\t%s:%d\n", key, origin.File, origin.Line)
+	}
+	w.Flush()
 }
 
 func init() {
 	rootCmd.AddCommand(envCmd)
+
+	envCmd.Flags().BoolVar(&envExplain, "explain", false, "List which file each variable comes from instead of printing shell commands")
+	envCmd.Flags().BoolVar(&envForce, "force", false, "Reload the files even if they did not change since the last run")
+	envCmd.Flags().Bool("schema-defaults", false, "Set variables no file sets to their default from the schema")
+	viper.BindPFlag("schema.defaults", envCmd.Flags().Lookup("schema-defaults"))
 }
\ No newline at end of file
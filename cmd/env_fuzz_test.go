@@ -0,0 +1,141 @@
+//go:build go1.18
+// +build go1.18
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/username/envtool/pkg/emitter"
+	"github.com/username/envtool/pkg/envfile"
+)
+
+// FuzzGenerateExportCommands parses arbitrary .env content and a managed
+// variable list, evaluates the bash output of generateExportCommands in a
+// real bash and checks that every variable ends up with exactly its value,
+// that invalid names are dropped and that nothing else ran
+func FuzzGenerateExportCommands(f *testing.F) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		f.Skip("bash not found")
+	}
+	builtin, err := exec.Command(bash, "--norc", "--noprofile", "-c", "compgen -v").Output()
+	if err != nil {
+		f.Skipf("cannot list bash variables: %v", err)
+	}
+	reserved := map[string]bool{}
+	for _, name := range strings.Fields(string(builtin)) {
+		reserved[name] = true
+	}
+	// skip leaves out names bash or envtool itself gives a meaning to, and
+	// values bash cannot hold
+	skip := func(key, value string) bool {
+		return reserved[key] || strings.HasPrefix(key, "BASH") || strings.HasPrefix(key, "ENVTOOL_") || strings.ContainsRune(value, 0)
+	}
+
+	for _, seed := range []struct{ content, managed string }{
+		{"FOO=bar\n", ""},
+		{"FOO;rm -rf ~=x\n", ""},
+		{"A='$(touch pwned)'\n", "OLD"},
+		{"A=\"`touch pwned`\"\n", "B"},
+		{"A=\"it's\"\nB='say \"hi\"'\n", ""},
+		{"A=\"line\\nbreak\"\n", "A,B"},
+		{"A='it''s'\n", ""},
+		{"A=x; touch pwned\n", "$(touch pwned)"},
+		{"A=\"$(touch pwned)\" B=${A}\n", "X;touch pwned,Y"},
+		{"", "A`touch pwned`,B=$(touch pwned)"},
+	} {
+		f.Add(seed.content, seed.managed)
+	}
+
+	f.Fuzz(func(t *testing.T, content, managed string) {
+		doc := envfile.ParseDocument(".env", []byte(content))
+		values, _ := doc.Values(func(string) (string, bool) { return "", false })
+		newVars := map[string]string{}
+		for key, value := range values {
+			if !skip(key, value) {
+				newVars[key] = value
+			}
+		}
+
+		// Every other managed variable has a value to restore
+		state := envState{Managed: []string{}, Saved: map[string]string{}}
+		seen := map[string]bool{}
+		for i, key := range strings.Split(managed, ",") {
+			value := "'$(touch pwned)'" + key
+			if key == "" || seen[key] || skip(key, value) {
+				continue
+			}
+			seen[key] = true
+			state.Managed = append(state.Managed, key)
+			if i%2 == 0 {
+				state.Saved[key] = value
+			}
+		}
+
+		em := &emitter.Posix{}
+		lookupEnv := func(string) (string, bool) { return "", false }
+		script := generateExportCommands(state, newVars, nil, lookupEnv, em) + "\n"
+
+		// Only valid names can be read back; every other one must have
+		// been dropped without running anything
+		want := map[string]string{}
+		for _, key := range state.Managed {
+			if value, ok := state.Saved[key]; ok {
+				want[key] = "set " + value
+			} else {
+				want[key] = "unset"
+			}
+		}
+		for key, value := range newVars {
+			want[key] = "set " + value
+		}
+		keys := []string{}
+		for key := range want {
+			if em.ValidKey(key) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		var expected bytes.Buffer
+		for _, key := range keys {
+			expected.WriteString(want[key] + "\x00")
+			script += `if [ -n "${` + key + `+x}" ]; then printf 'set %s\0' "$` + key + `"; else printf 'unset\0'; fi` + "\n"
+		}
+
+		dir, err := ioutil.TempDir("", "envtool-fuzz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(bash, "--norc", "--noprofile", "-c", script)
+		cmd.Dir = dir
+		cmd.Env = []string{}
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("bash failed: %v\n%s\nscript:\n%s", err, stderr.String(), script)
+		}
+		if stderr.Len() > 0 {
+			t.Fatalf("unexpected stderr %q\nscript:\n%s", stderr.String(), script)
+		}
+		if stdout.String() != expected.String() {
+			t.Fatalf("got %q, want %q\nscript:\n%s", stdout.String(), expected.String(), script)
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) > 0 {
+			t.Fatalf("script created %s\nscript:\n%s", entries[0].Name(), script)
+		}
+	})
+}
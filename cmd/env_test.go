@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/username/envtool/pkg/emitter"
 )
 
 func TestGenerateExportCommands(t *testing.T) {
@@ -12,7 +18,11 @@ func TestGenerateExportCommands(t *testing.T) {
 	testCases := []struct {
 		name        string
 		currentVars []string
+		saved       map[string]string
+		environ     map[string]string
 		newVars     map[string]string
+		fingerprint string
+		resolved    string
 		shellType   string
 		expected    []string
 	}{
@@ -25,9 +35,9 @@ func TestGenerateExportCommands(t *testing.T) {
 			},
 			shellType: "bash",
 			expected: []string{
-				"export BAZ=qux",
-				"export FOO=bar",
-				"export ENVTOOL_MANAGED_ENV_VARS=BAZ,FOO",
+				"export BAZ='qux'",
+				"export FOO='bar'",
+				"export ENVTOOL_MANAGED_ENV_VARS='BAZ,FOO'",
 			},
 		},
 		{
@@ -41,9 +51,9 @@ func TestGenerateExportCommands(t *testing.T) {
 			expected: []string{
 				"unset FOO",
 				"unset BAR",
-				"export BAZ=updated",
-				"export QUX=new",
-				"export ENVTOOL_MANAGED_ENV_VARS=BAZ,QUX",
+				"export BAZ='updated'",
+				"export QUX='new'",
+				"export ENVTOOL_MANAGED_ENV_VARS='BAZ,QUX'",
 			},
 		},
 		{
@@ -55,16 +65,163 @@ func TestGenerateExportCommands(t *testing.T) {
 			},
 			shellType: "bash",
 			expected: []string{
-				"export BAR=unchanged",
-				"export FOO=unchanged",
-				"export ENVTOOL_MANAGED_ENV_VARS=BAR,FOO",
+				"export BAR='unchanged'",
+				"export FOO='unchanged'",
+				"export ENVTOOL_MANAGED_ENV_VARS='BAR,FOO'",
+			},
+		},
+		{
+			name:        "Save pre-existing values",
+			currentVars: []string{},
+			environ: map[string]string{
+				"EDITOR": "vim",
+				"OTHER":  "untouched",
+			},
+			newVars: map[string]string{
+				"EDITOR": "nano",
+				"FOO":    "bar",
+			},
+			shellType: "bash",
+			expected: []string{
+				"export EDITOR='nano'",
+				"export FOO='bar'",
+				"export ENVTOOL_MANAGED_ENV_VARS='EDITOR,FOO'",
+				"export ENVTOOL_SAVED_ENV='" + encodeSaved(map[string]string{"EDITOR": "vim"}) + "'",
+			},
+		},
+		{
+			name:        "Managed values are not saved again",
+			currentVars: []string{"EDITOR"},
+			saved:       map[string]string{"EDITOR": "vim"},
+			environ:     map[string]string{"EDITOR": "nano"},
+			newVars: map[string]string{
+				"EDITOR": "emacs",
+			},
+			shellType: "bash",
+			expected: []string{
+				"export EDITOR='emacs'",
+				"export ENVTOOL_MANAGED_ENV_VARS='EDITOR'",
+				"export ENVTOOL_SAVED_ENV='" + encodeSaved(map[string]string{"EDITOR": "vim"}) + "'",
+			},
+		},
+		{
+			name:        "Restore saved values on unload",
+			currentVars: []string{"EDITOR", "FOO", "AWS_PROFILE"},
+			saved: map[string]string{
+				"EDITOR":      "vim",
+				"AWS_PROFILE": "my profile",
+			},
+			newVars:   map[string]string{},
+			shellType: "bash",
+			expected: []string{
+				"export EDITOR='vim'",
+				"unset FOO",
+				"export AWS_PROFILE='my profile'",
+				"unset ENVTOOL_MANAGED_ENV_VARS",
+				"unset ENVTOOL_SAVED_ENV",
+			},
+		},
+		{
+			name:        "Fish syntax",
+			currentVars: []string{"OLD", "EDITOR"},
+			saved:       map[string]string{"EDITOR": "vim"},
+			newVars: map[string]string{
+				"PLAIN":  "bar",
+				"QUOTED": `it's a \path`,
+				"EMPTY":  "",
+				"SPACES": "'already quoted'",
+			},
+			shellType: "fish",
+			expected: []string{
+				"set -e OLD",
+				"set -gx EDITOR vim",
+				"set -gx EMPTY ''",
+				"set -gx PLAIN bar",
+				`set -gx QUOTED 'it\'s a \\path'`,
+				`set -gx SPACES '\'already quoted\''`,
+				"set -gx ENVTOOL_MANAGED_ENV_VARS EMPTY,PLAIN,QUOTED,SPACES",
+				"set -e ENVTOOL_SAVED_ENV",
+			},
+		},
+		{
+			name:        "Injection attempts",
+			currentVars: []string{"OLD;touch pwned"},
+			newVars: map[string]string{
+				"FOO;rm -rf ~": "x",
+				"QUOTED":       "'$(touch pwned)'",
+			},
+			shellType: "bash",
+			expected: []string{
+				`export QUOTED=''"'"'$(touch pwned)'"'"''`,
+				"export ENVTOOL_MANAGED_ENV_VARS='QUOTED'",
+			},
+		},
+		{
+			name:        "New fingerprint",
+			currentVars: []string{},
+			newVars: map[string]string{
+				"FOO": "bar",
+			},
+			environ:     map[string]string{FingerprintKey: "xyz"},
+			fingerprint: "abc",
+			shellType:   "bash",
+			expected: []string{
+				"export FOO='bar'",
+				"export ENVTOOL_MANAGED_ENV_VARS='FOO'",
+				"export ENVTOOL_FINGERPRINT='abc'",
+			},
+		},
+		{
+			name:        "Unchanged fingerprint",
+			currentVars: []string{"FOO"},
+			environ:     map[string]string{FingerprintKey: "abc", ResolvedKey: "old", "FOO": "bar"},
+			newVars: map[string]string{
+				"FOO": "bar",
+			},
+			fingerprint: "abc",
+			resolved:    "new",
+			shellType:   "bash",
+			expected: []string{
+				"export FOO='bar'",
+				"export ENVTOOL_MANAGED_ENV_VARS='FOO'",
+				"export ENVTOOL_RESOLVED='new'",
+			},
+		},
+		{
+			name:        "Stale resolved cache",
+			currentVars: []string{},
+			environ:     map[string]string{ResolvedKey: "old"},
+			newVars:     map[string]string{},
+			fingerprint: "abc",
+			shellType:   "bash",
+			expected: []string{
+				"export ENVTOOL_FINGERPRINT='abc'",
+				"unset ENVTOOL_RESOLVED",
+			},
+		},
+		{
+			name:        "Fingerprint outside any project",
+			currentVars: []string{},
+			newVars:     map[string]string{},
+			fingerprint: "abc",
+			shellType:   "bash",
+			expected: []string{
+				"export ENVTOOL_FINGERPRINT='abc'",
 			},
 		},
 	}
 	
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			output := generateExportCommands(tc.currentVars, tc.newVars, tc.shellType)
+			state := envState{Managed: tc.currentVars, Saved: tc.saved}
+			lookupEnv := func(key string) (string, bool) {
+				value, ok := tc.environ[key]
+				return value, ok
+			}
+			em, err := emitter.ForShell(tc.shellType)
+			assert.NoError(t, err)
+			meta := map[string]string{FingerprintKey: tc.fingerprint, ResolvedKey: tc.resolved}
+			output := generateExportCommands(state, tc.newVars, meta, lookupEnv, em)
 			lines := strings.Split(strings.TrimSpace(output), "\n")
 			
 			assert.Equal(t, len(tc.expected), len(lines), "Number of output lines doesn't match expected")
@@ -74,4 +231,174 @@ func TestGenerateExportCommands(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestEnvCmd_WalkExplain(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-env-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	tempDir, err = filepath.EvalSymlinks(tempDir)
+	assert.NoError(t, err)
+
+	api := filepath.Join(tempDir, "services", "api")
+	assert.NoError(t, os.MkdirAll(api, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tempDir, ".env"), []byte("SHARED=repo\nREGION=eu\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(api, ".env"), []byte("# api\nSHARED=api\n"), 0644))
+	allowFiles(t, filepath.Join(tempDir, ".env"), filepath.Join(api, ".env"))
+
+	origDir, err := os.Getwd()
+	assert.NoError(t, err)
+	defer os.Chdir(origDir)
+	assert.NoError(t, os.Chdir(api))
+
+	defer func() {
+		viper.Set("walk", false)
+		viper.Set("walk-boundary", "home")
+		envExplain = false
+		envCmd.SetOut(nil)
+	}()
+	viper.Set("walk", true)
+	viper.Set("walk-boundary", tempDir)
+
+	var out bytes.Buffer
+	envCmd.SetOut(&out)
+	assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+	assert.Contains(t, out.String(), "export REGION='eu'\n")
+	assert.Contains(t, out.String(), "export SHARED='api'\n")
+
+	out.Reset()
+	envExplain = true
+	assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+	assert.Equal(t, "REGION  "+filepath.Join(tempDir, ".env")+":2\n"+
+		"SHARED  "+filepath.Join(api, ".env")+":2\n", out.String())
+}
+
+func TestLoadEnvState(t *testing.T) {
+	environ := map[string]string{
+		ManagedEnvVarsKey: "EDITOR,FOO",
+		SavedEnvVarsKey:   encodeSaved(map[string]string{"EDITOR": "vim"}),
+	}
+	state, err := loadEnvState(func(key string) string { return environ[key] })
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"EDITOR", "FOO"}, state.Managed)
+	assert.Equal(t, map[string]string{"EDITOR": "vim"}, state.Saved)
+
+	environ[SavedEnvVarsKey] = "not base64!"
+	state, err = loadEnvState(func(key string) string { return environ[key] })
+	assert.Error(t, err)
+	assert.Equal(t, []string{"EDITOR", "FOO"}, state.Managed)
+	assert.Empty(t, state.Saved)
+}
+
+// inTempProject runs f in a temporary directory holding a .env file
+func inTempProject(tb testing.TB, content string, f func(dir string)) {
+	tempDir, err := ioutil.TempDir("", "envtool-env-test")
+	if err != nil {
+		tb.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	if err := ioutil.WriteFile(filepath.Join(tempDir, ".env"), []byte(content), 0644); err != nil {
+		tb.Fatalf("Failed to write .env: %v", err)
+	}
+	allowFiles(tb, filepath.Join(tempDir, ".env"))
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		tb.Fatalf("Failed to get working directory: %v", err)
+	}
+	defer os.Chdir(origDir)
+	if err := os.Chdir(tempDir); err != nil {
+		tb.Fatalf("Failed to change directory: %v", err)
+	}
+
+	origFingerprint, hadFingerprint := os.LookupEnv(FingerprintKey)
+	defer func() {
+		if hadFingerprint {
+			os.Setenv(FingerprintKey, origFingerprint)
+		} else {
+			os.Unsetenv(FingerprintKey)
+		}
+		envCmd.SetOut(nil)
+	}()
+	f(tempDir)
+}
+
+func TestEnvCmd_Fingerprint(t *testing.T) {
+	inTempProject(t, "FOO=bar\n", func(dir string) {
+		var out bytes.Buffer
+		envCmd.SetOut(&out)
+		assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		fingerprint := currentFingerprint("bash")
+		assert.Contains(t, out.String(), "export FOO='bar'\n")
+		assert.Contains(t, out.String(), "export ENVTOOL_FINGERPRINT='"+fingerprint+"'")
+
+		// Nothing changed: no output
+		os.Setenv(FingerprintKey, fingerprint)
+		out.Reset()
+		assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		assert.Equal(t, "", out.String())
+
+		// Another shell type needs its own output
+		assert.NotEqual(t, fingerprint, currentFingerprint("zsh"))
+
+		// --force reloads anyway
+		envForce = true
+		assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		envForce = false
+		assert.Contains(t, out.String(), "export FOO='bar'\n")
+
+		// Changing the file changes the fingerprint
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".env"), []byte("FOO=baz\n"), 0644))
+		allowFiles(t, filepath.Join(dir, ".env"))
+		out.Reset()
+		assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		assert.Contains(t, out.String(), "export FOO='baz'\n")
+		assert.NotEqual(t, fingerprint, currentFingerprint("bash"))
+
+		// So does creating a file that may be loaded
+		fingerprint = currentFingerprint("bash")
+		viper.Set("env-file", []string{".env", ".env.local"})
+		defer viper.Set("env-file", []string{".env"})
+		before := currentFingerprint("bash")
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".env.local"), []byte("LOCAL=1\n"), 0644))
+		assert.NotEqual(t, before, currentFingerprint("bash"))
+		assert.NotEqual(t, fingerprint, before)
+	})
+}
+
+// BenchmarkEnvCmd_Unchanged measures the prompt hook's common case, where
+// no file changed since the last run
+func BenchmarkEnvCmd_Unchanged(b *testing.B) {
+	inTempProject(b, "FOO=bar\nURL=http://${FOO}:8080\n", func(dir string) {
+		os.Setenv(FingerprintKey, currentFingerprint("bash"))
+		var out bytes.Buffer
+		envCmd.SetOut(&out)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := envCmd.RunE(envCmd, []string{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StopTimer()
+		if out.Len() != 0 {
+			b.Fatalf("unexpected output %q", out.String())
+		}
+	})
+}
+
+// BenchmarkEnvCmd_Changed measures a full reload for comparison
+func BenchmarkEnvCmd_Changed(b *testing.B) {
+	inTempProject(b, "FOO=bar\nURL=http://${FOO}:8080\n", func(dir string) {
+		os.Unsetenv(FingerprintKey)
+		var out bytes.Buffer
+		envCmd.SetOut(&out)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			out.Reset()
+			if err := envCmd.RunE(envCmd, []string{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
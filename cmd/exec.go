@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	execNoOverride bool
+	execClean      bool
+)
+
+// execCmd represents the exec command
+var execCmd = &cobra.Command{
+	Use:   "exec [flags] -- command [args...]",
+	Short: "Run a command with the variables from .env files",
+	Long: `Load the .env files like envtool env and run a command with their
+variables, for use in CI jobs, cron, systemd units and Makefiles that do not
+go through the shell hook.
+
+The command replaces envtool, so that it receives signals directly and its
+exit status is that of envtool exec. It is looked up in the PATH it runs
+with. Variables from the .env files override those already set, unless
+--no-override is given; with --clean, the command only gets the variables
+from the .env files.
+
+Untrusted files are skipped, and blocked variables left out, with a notice
+on stderr. Put -- before the command so that its flags are not taken for
+envtool's.`,
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		merged, _, err := loadEnvFiles(loadOptions{evaluate: true}, cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+
+		var base []string
+		if !execClean {
+			base = os.Environ()
+		}
+		env := execEnviron(base, merged.Values, !execNoOverride)
+
+		path, err := lookPath(args[0], env)
+		if err != nil {
+			return err
+		}
+		return execProcess(path, args, env)
+	},
+}
+
+// execEnviron merges values into base, an environment in the form of
+// os.Environ. Variables of base keep their position; those that are new
+// are appended in sorted order. With override, values replace the ones
+// base already has.
+func execEnviron(base []string, values map[string]string, override bool) []string {
+	env := make([]string, 0, len(base)+len(values))
+	seen := make(map[string]bool, len(base))
+	for _, kv := range base {
+		key := kv
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			key = kv[:i]
+		}
+		if value, ok := values[key]; ok && override {
+			kv = key + "=" + value
+		}
+		env = append(env, kv)
+		seen[key] = true
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		env = append(env, key+"="+values[key])
+	}
+	return env
+}
+
+// lookPath finds the executable for name like a shell would with the
+// given environment: in its PATH, or envtool's own if it has none. Names
+// containing a slash are not searched for.
+func lookPath(name string, env []string) (string, error) {
+	if strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) {
+		return exec.LookPath(name)
+	}
+
+	path := os.Getenv("PATH")
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "PATH=") {
+			path = kv[len("PATH="):]
+			break
+		}
+	}
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			// An empty entry stands for the current directory
+			dir = "."
+		}
+		if file, ok := findExecutable(filepath.Join(dir, name), env); ok {
+			return file, nil
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+
+	execCmd.Flags().SetInterspersed(false)
+	execCmd.Flags().BoolVar(&execNoOverride, "no-override", false, "Keep variables that are already set instead of overriding them")
+	execCmd.Flags().BoolVar(&execClean, "clean", false, "Start from an empty environment instead of the current one")
+}
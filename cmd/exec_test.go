@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecEnviron(t *testing.T) {
+	base := []string{"HOME=/home/user", "PATH=/bin", "EMPTY="}
+	values := map[string]string{"PATH": "/opt/bin", "B": "2", "A": "1=1"}
+
+	assert.Equal(t, []string{"HOME=/home/user", "PATH=/opt/bin", "EMPTY=", "A=1=1", "B=2"}, execEnviron(base, values, true))
+	assert.Equal(t, []string{"HOME=/home/user", "PATH=/bin", "EMPTY=", "A=1=1", "B=2"}, execEnviron(base, values, false))
+	assert.Equal(t, []string{"A=1=1", "B=2", "PATH=/opt/bin"}, execEnviron(nil, values, false))
+}
+
+func TestExecCmd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("looks up a script without an extension")
+	}
+	inTempProject(t, "", func(dir string) {
+		// A stand-in for the command, found through the PATH of the .env
+		// file
+		bin := filepath.Join(dir, "bin")
+		assert.NoError(t, os.Mkdir(bin, 0755))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(bin, "tool"), []byte("#!/bin/sh\n"), 0755))
+		assert.NoError(t, ioutil.WriteFile(".env", []byte("GREETING=hello\nHOME=/from/env\nLD_PRELOAD=evil.so\nPATH="+bin+"\n"), 0644))
+		allowFiles(t, filepath.Join(dir, ".env"))
+
+		var gotPath string
+		var gotArgs, gotEnv []string
+		origExec := execProcess
+		execProcess = func(path string, args []string, env []string) error {
+			gotPath, gotArgs, gotEnv = path, args, env
+			return nil
+		}
+		defer func() {
+			execProcess = origExec
+			execNoOverride, execClean = false, false
+		}()
+
+		stderr := captureStderr(t, func() {
+			assert.NoError(t, execCmd.RunE(execCmd, []string{"tool", "--flag", "arg"}))
+		})
+		assert.Contains(t, stderr, "not setting LD_PRELOAD")
+		assert.Equal(t, filepath.Join(bin, "tool"), gotPath)
+		assert.Equal(t, []string{"tool", "--flag", "arg"}, gotArgs)
+		assert.Contains(t, gotEnv, "GREETING=hello")
+		assert.Contains(t, gotEnv, "HOME=/from/env")
+		assert.NotContains(t, gotEnv, "LD_PRELOAD=evil.so")
+		assert.Equal(t, len(os.Environ())+1, len(gotEnv))
+
+		// Without overriding, the command is looked up in the current PATH
+		execNoOverride = true
+		captureStderr(t, func() {
+			assert.Error(t, execCmd.RunE(execCmd, []string{"tool"}))
+			assert.NoError(t, execCmd.RunE(execCmd, []string{filepath.Join(bin, "tool")}))
+		})
+		assert.Contains(t, gotEnv, "GREETING=hello")
+		assert.Contains(t, gotEnv, "HOME="+os.Getenv("HOME"))
+		assert.Contains(t, gotEnv, "PATH="+os.Getenv("PATH"))
+
+		execNoOverride, execClean = false, true
+		captureStderr(t, func() {
+			assert.NoError(t, execCmd.RunE(execCmd, []string{"tool"}))
+		})
+		assert.Equal(t, []string{"GREETING=hello", "HOME=/from/env", "PATH=" + bin}, gotEnv)
+	})
+}
+
+func TestLookPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("looks up a script without an extension")
+	}
+	dir, err := ioutil.TempDir("", "envtool-lookpath")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Directories and files that are not executable are passed over
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	assert.NoError(t, os.MkdirAll(filepath.Join(first, "tool"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(first, "other"), []byte("#!/bin/sh\n"), 0644))
+	assert.NoError(t, os.Mkdir(second, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(second, "tool"), []byte("#!/bin/sh\n"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(second, "other"), []byte("#!/bin/sh\n"), 0755))
+
+	origPath := os.Getenv("PATH")
+	env := []string{"HOME=/home/user", "PATH=" + first + string(filepath.ListSeparator) + second}
+	path, err := lookPath("tool", env)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(second, "tool"), path)
+	path, err = lookPath("other", env)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(second, "other"), path)
+	assert.Equal(t, origPath, os.Getenv("PATH"))
+
+	_, err = lookPath("missing", env)
+	assert.EqualError(t, err, `exec: "missing": executable file not found in $PATH`)
+
+	// Names with a slash are not searched for
+	_, err = lookPath(filepath.Join("second", "tool"), env)
+	assert.Error(t, err)
+	path, err = lookPath(filepath.Join(second, "tool"), env)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(second, "tool"), path)
+}
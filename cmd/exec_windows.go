@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// execProcess runs the program at path and exits with its status, since
+// Windows cannot replace a running process. It only returns if the
+// program cannot be started.
+var execProcess = func(path string, args []string, env []string) error {
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	} else if err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
+
+// findExecutable returns file, or file with one of the extensions in the
+// PATHEXT of env, if it is a regular file
+func findExecutable(file string, env []string) (string, bool) {
+	pathext := os.Getenv("PATHEXT")
+	for _, kv := range env {
+		if len(kv) > len("PATHEXT=") && strings.EqualFold(kv[:len("PATHEXT=")], "PATHEXT=") {
+			pathext = kv[len("PATHEXT="):]
+			break
+		}
+	}
+	if pathext == "" {
+		pathext = ".com;.exe;.bat;.cmd"
+	}
+
+	candidates := []string{}
+	if filepath.Ext(file) != "" {
+		candidates = append(candidates, file)
+	}
+	for _, ext := range filepath.SplitList(pathext) {
+		if ext != "" {
+			candidates = append(candidates, file+ext)
+		}
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/username/envtool/pkg/format"
+)
+
+var (
+	exportFormat    string
+	exportName      string
+	exportNamespace string
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Print the variables from .env files in another format",
+	Long: `Load the .env files like envtool env and print the resulting variables in
+a format other tools read:
+
+  json           a JSON object
+  yaml           a YAML mapping
+  dotenv         a .env file, quoted like envtool set writes values
+  docker         a file for docker run --env-file, without quotes
+  systemd        a file for the EnvironmentFile= setting of systemd units
+  k8s-configmap  a Kubernetes ConfigMap manifest named by --name
+  k8s-secret     a Kubernetes Secret manifest, with base64-encoded values
+
+Variables are sorted by name, so the output only changes when they do.
+Untrusted files are skipped, and blocked variables left out, with a notice
+on stderr. Encrypted values and secret references are resolved, so the
+output may hold secrets.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := format.ForName(exportFormat, format.Options{Name: exportName, Namespace: exportNamespace})
+		if err != nil {
+			return err
+		}
+		merged, _, err := loadEnvFiles(loadOptions{evaluate: true}, cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+		output, err := f.Format(merged.Values)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(cmd.OutOrStdout(), output)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "dotenv", "Output format: "+strings.Join(format.Names, ", "))
+	exportCmd.Flags().StringVar(&exportName, "name", "env", "Name of the Kubernetes ConfigMap or Secret")
+	exportCmd.Flags().StringVar(&exportNamespace, "namespace", "", "Namespace of the Kubernetes ConfigMap or Secret")
+}
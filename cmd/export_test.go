@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportCmd(t *testing.T) {
+	inTempProject(t, "B=2\nA='x y'\nURL=http://${A}\nLD_PRELOAD=x\n", func(dir string) {
+		defer func() {
+			exportFormat, exportName = "dotenv", "env"
+			exportCmd.SetOut(nil)
+			exportCmd.SetErr(nil)
+		}()
+		var out, stderr bytes.Buffer
+		exportCmd.SetOut(&out)
+		exportCmd.SetErr(&stderr)
+
+		exportFormat = "json"
+		assert.NoError(t, exportCmd.RunE(exportCmd, []string{}))
+		assert.Equal(t, "{\n  \"A\": \"x y\",\n  \"B\": \"2\",\n  \"URL\": \"http://x y\"\n}\n", out.String())
+		assert.Contains(t, stderr.String(), "not setting LD_PRELOAD")
+
+		out.Reset()
+		exportFormat, exportName = "k8s-configmap", "web"
+		assert.NoError(t, exportCmd.RunE(exportCmd, []string{}))
+		assert.Equal(t, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\ndata:\n  A: x y\n  B: \"2\"\n  URL: http://x y\n", out.String())
+
+		exportFormat = "xml"
+		assert.EqualError(t, exportCmd.RunE(exportCmd, []string{}), `unsupported format "xml"`)
+
+		// The same pipeline as envtool env, so untrusted files are skipped
+		assert.NoError(t, ioutil.WriteFile(".env", []byte("B=3\n"), 0644))
+		out.Reset()
+		stderr.Reset()
+		exportFormat = "docker"
+		assert.NoError(t, exportCmd.RunE(exportCmd, []string{}))
+		assert.Empty(t, out.String())
+		assert.Contains(t, stderr.String(), "changed since it was allowed")
+	})
+}
+
+func TestExportCmd_WithConfigFile(t *testing.T) {
+	inTempProject(t, "A=1\n", func(dir string) {
+		config := filepath.Join(dir, "envtool.yaml")
+		assert.NoError(t, ioutil.WriteFile(config, []byte("walk: false\n"), 0644))
+		defer func() {
+			exportFormat, exportName = "dotenv", "env"
+			cfgFile, verbose = "", false
+			rootCmd.SetArgs(nil)
+			rootCmd.SetOut(nil)
+			rootCmd.SetErr(nil)
+		}()
+
+		// Nothing but the export reaches stdout, so it stays valid JSON
+		var out, stderr bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetErr(&stderr)
+		rootCmd.SetArgs([]string{"--config", config, "export", "--format", "json"})
+		r, w, err := os.Pipe()
+		assert.NoError(t, err)
+		origStdout := os.Stdout
+		os.Stdout = w
+		err = rootCmd.Execute()
+		os.Stdout = origStdout
+		w.Close()
+		assert.NoError(t, err)
+		stdout, err := ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Empty(t, string(stdout))
+		var values map[string]string
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &values), out.String())
+		assert.Equal(t, map[string]string{"A": "1"}, values)
+		assert.Empty(t, stderr.String())
+
+		// With --verbose, the config file is reported on stderr
+		out.Reset()
+		rootCmd.SetArgs([]string{"--config", config, "--verbose", "export", "--format", "json"})
+		assert.NoError(t, rootCmd.Execute())
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &values), out.String())
+		assert.Equal(t, "Using config file: "+config+"\n", stderr.String())
+	})
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/username/envtool/pkg/envfile"
+)
+
+// presetLayered is the file layout used by Next.js and Vite: a base file,
+// local overrides, and per-environment files with their own overrides
+const presetLayered = "layered"
+
+// envFileNames returns the configured .env file names in merge order,
+// expanded by the selected preset
+func envFileNames() ([]string, error) {
+	files := viper.GetStringSlice("env-file")
+	if len(files) == 0 {
+		files = []string{".env"}
+	}
+
+	switch p := viper.GetString("preset"); p {
+	case "":
+		return files, nil
+	case presetLayered:
+		env := strings.TrimSpace(viper.GetString("environment"))
+		if strings.ContainsAny(env, `/\`) || env == "." || env == ".." {
+			return nil, fmt.Errorf("invalid environment name %q", env)
+		}
+		var names []string
+		for _, file := range files {
+			names = append(names, file, file+".local")
+			if env != "" {
+				names = append(names, file+"."+env, file+"."+env+".local")
+			}
+		}
+		return names, nil
+	default:
+		return nil, fmt.Errorf("unknown preset %q", p)
+	}
+}
+
+// envFilePaths returns the .env files to load, in merge order. With
+// --walk, every relative file name is searched for between the current
+// directory and the walk boundary, farthest directory first; absolute
+// paths are loaded after the discovered files.
+func envFilePaths() ([]string, error) {
+	return walkEnvFiles(envfile.Discover)
+}
+
+// envFileCandidates returns every path envFilePaths looks at, including
+// those where no file exists yet
+func envFileCandidates() ([]string, error) {
+	return walkEnvFiles(envfile.Candidates)
+}
+
+// walkEnvFiles adds the encrypted variant of each configured file name and
+// expands the names with search, which is envfile.Discover or
+// envfile.Candidates, when --walk is set
+func walkEnvFiles(search func(string, []string, envfile.DiscoverOptions) ([]string, error)) ([]string, error) {
+	names, err := envFileNames()
+	if err != nil {
+		return nil, err
+	}
+	names = withEncrypted(names)
+	if !viper.GetBool("walk") {
+		return names, nil
+	}
+
+	var relative, absolute []string
+	for _, name := range names {
+		if filepath.IsAbs(name) {
+			absolute = append(absolute, name)
+		} else {
+			relative = append(relative, name)
+		}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	boundary, err := resolveWalkBoundary(viper.GetString("walk-boundary"))
+	if err != nil {
+		return nil, err
+	}
+	found, err := search(cwd, relative, envfile.DiscoverOptions{
+		Boundary: boundary,
+		Markers:  viper.GetStringSlice("walk-marker"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(found, absolute...), nil
+}
+
+// targetEnvFile returns the single .env file edited by commands such as
+// set and unset
+func targetEnvFile() (string, error) {
+	files := viper.GetStringSlice("env-file")
+	switch len(files) {
+	case 0:
+		return ".env", nil
+	case 1:
+		return files[0], nil
+	}
+	return "", fmt.Errorf("expected a single --env-file, got %d", len(files))
+}
+
+// resolveWalkBoundary turns a --walk-boundary value into a directory
+func resolveWalkBoundary(value string) (string, error) {
+	switch strings.TrimSpace(value) {
+	case "", "root":
+		return string(filepath.Separator), nil
+	case "home":
+		home, err := os.UserHomeDir()
+		if err != nil || home == "" {
+			return "", fmt.Errorf("--walk-boundary home requires HOME to be set")
+		}
+		return home, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvFileNames(t *testing.T) {
+	defer func() {
+		viper.Set("env-file", []string{".env"})
+		viper.Set("preset", "")
+		viper.Set("environment", "")
+	}()
+
+	testCases := []struct {
+		name        string
+		files       []string
+		preset      string
+		environment string
+		expected    []string
+		wantErr     bool
+	}{
+		{
+			name:     "Single file",
+			files:    []string{".env"},
+			expected: []string{".env"},
+		},
+		{
+			name:     "Ordered list",
+			files:    []string{"base.env", "override.env"},
+			expected: []string{"base.env", "override.env"},
+		},
+		{
+			name:     "Layered without environment",
+			files:    []string{".env"},
+			preset:   "layered",
+			expected: []string{".env", ".env.local"},
+		},
+		{
+			name:        "Layered with environment",
+			files:       []string{".env"},
+			preset:      "layered",
+			environment: "staging",
+			expected:    []string{".env", ".env.local", ".env.staging", ".env.staging.local"},
+		},
+		{
+			name:        "Environment must be a plain name",
+			files:       []string{".env"},
+			preset:      "layered",
+			environment: "../etc",
+			wantErr:     true,
+		},
+		{
+			name:    "Unknown preset",
+			files:   []string{".env"},
+			preset:  "nope",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			viper.Set("env-file", tc.files)
+			viper.Set("preset", tc.preset)
+			viper.Set("environment", tc.environment)
+
+			names, err := envFileNames()
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, names)
+		})
+	}
+}
+
+func TestEnvCmd_LayeredPreset(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-files-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	base := filepath.Join(tempDir, ".env")
+	files := map[string]string{
+		base:                       "API_URL=http://localhost\nLOG_LEVEL=info\nNAME=app\n",
+		base + ".production":       "API_URL=https://api.example.com\nLOG_LEVEL=warn\n",
+		base + ".production.local": "LOG_LEVEL=debug\nBROKEN LINE\n",
+	}
+	for path, content := range files {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+		allowFiles(t, path)
+	}
+
+	defer func() {
+		viper.Set("env-file", []string{".env"})
+		viper.Set("preset", "")
+		viper.Set("environment", "")
+		envCmd.SetOut(nil)
+	}()
+	viper.Set("env-file", []string{base})
+	viper.Set("preset", "layered")
+	viper.Set("environment", "production")
+
+	var out bytes.Buffer
+	envCmd.SetOut(&out)
+	assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+	assert.Equal(t, "export API_URL='https://api.example.com'\n"+
+		"export LOG_LEVEL='debug'\n"+
+		"export NAME='app'\n"+
+		"export ENVTOOL_MANAGED_ENV_VARS='API_URL,LOG_LEVEL,NAME'\n"+
+		"export ENVTOOL_FINGERPRINT='"+currentFingerprint("bash")+"'", out.String())
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/username/envtool/pkg/envfile"
+	"github.com/username/envtool/pkg/format"
+)
+
+// Conflict policies of envtool import
+const (
+	conflictKeep      = "keep"
+	conflictOverwrite = "overwrite"
+	conflictFail      = "prompt-free-fail"
+)
+
+var (
+	importFormat     string
+	importService    string
+	importPID        int
+	importOnConflict string
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Add variables from another format to a .env file",
+	Long: `Read variables from a file, or from stdin if it is omitted or -, and add
+them to the .env file given by --env-file, creating it if needed. Values
+are quoted as needed, so they are never expanded when the file is loaded.
+
+The input format is taken from the file name, or given with --format:
+
+  json     a JSON object of strings, numbers and booleans
+  yaml     a YAML mapping of scalars
+  compose  the environment of a docker-compose service, chosen with
+           --service if the file has several
+  systemd  a file for the EnvironmentFile= setting of systemd units
+  environ  NUL-separated pairs, as in /proc/PID/environ or from env -0
+
+--pid PID reads the environment of a running process.
+
+Variables already set to another value are handled according to
+--on-conflict: keep leaves them alone, overwrite replaces them, and
+prompt-free-fail, the default, stops without changing the file. Names that
+are not valid .env keys are skipped with a warning.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch importOnConflict {
+		case conflictKeep, conflictOverwrite, conflictFail:
+		default:
+			return fmt.Errorf("unknown conflict policy %q; use %s, %s or %s", importOnConflict, conflictKeep, conflictOverwrite, conflictFail)
+		}
+
+		source, data, err := readImportSource(cmd, args)
+		if err != nil {
+			return err
+		}
+		name := importFormat
+		if name == "" {
+			if name = format.DetectInput(source, data); name == "" {
+				return fmt.Errorf("cannot tell the format of %s; use --format %s", source, strings.Join(format.InputNames, "|"))
+			}
+		}
+		vars, err := format.Parse(name, data, format.Options{Service: importService})
+		if err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+
+		envFilePath, err := targetEnvFile()
+		if err != nil {
+			return err
+		}
+		doc, err := envfile.ReadDocument(envFilePath)
+		if os.IsNotExist(err) {
+			doc = envfile.ParseDocument(envFilePath, nil)
+		} else if err != nil {
+			return err
+		}
+		current, _ := doc.Values(func(string) (string, bool) { return "", false })
+
+		keys := make([]string, 0, len(vars))
+		for key := range vars {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var added, conflicts []string
+		for _, key := range keys {
+			if !envfile.IsValidKey(key) {
+				fmt.Fprintf(cmd.ErrOrStderr(), "envtool: skipping %q: not a valid key name\n", key)
+				continue
+			}
+			if doc.Lookup(key) == nil {
+				added = append(added, key)
+			} else if current[key] != vars[key] {
+				conflicts = append(conflicts, key)
+			}
+		}
+		if len(conflicts) > 0 && importOnConflict == conflictFail {
+			return fmt.Errorf("already set to other values in %s: %s; use --on-conflict %s or %s",
+				envFilePath, strings.Join(conflicts, ", "), conflictKeep, conflictOverwrite)
+		}
+
+		for _, key := range added {
+			doc.Set(key, vars[key])
+		}
+		overwritten := 0
+		if importOnConflict == conflictOverwrite {
+			for _, key := range conflicts {
+				doc.Set(key, vars[key])
+			}
+			overwritten = len(conflicts)
+		}
+		if len(added) > 0 || overwritten > 0 {
+			if err := doc.WriteFile(envFilePath); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: %d added, %d overwritten, %d kept\n",
+			envFilePath, len(added), overwritten, len(conflicts)-overwritten)
+		return nil
+	},
+}
+
+// readImportSource reads the input of envtool import and returns its name
+// for messages along with its content
+func readImportSource(cmd *cobra.Command, args []string) (string, []byte, error) {
+	switch {
+	case importPID > 0 && len(args) > 0:
+		return "", nil, fmt.Errorf("give either a file or --pid")
+	case importPID > 0:
+		source := fmt.Sprintf("/proc/%d/environ", importPID)
+		data, err := ioutil.ReadFile(source)
+		return source, data, err
+	case len(args) == 0 || args[0] == "-":
+		data, err := ioutil.ReadAll(cmd.InOrStdin())
+		return "stdin", data, err
+	}
+	data, err := ioutil.ReadFile(args[0])
+	return args[0], data, err
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().StringVarP(&importFormat, "format", "f", "", "Input format: "+strings.Join(format.InputNames, ", ")+" (default: from the file name)")
+	importCmd.Flags().StringVar(&importService, "service", "", "docker-compose service to import the environment of")
+	importCmd.Flags().IntVar(&importPID, "pid", 0, "Import the environment of a running process")
+	importCmd.Flags().StringVar(&importOnConflict, "on-conflict", conflictFail, "What to do with variables already set to other values: keep, overwrite or prompt-free-fail")
+}
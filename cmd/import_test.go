@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportCmd(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-import-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	envPath := filepath.Join(tempDir, ".env")
+	assert.NoError(t, ioutil.WriteFile(envPath, []byte("# app\nHOST=localhost\nPORT='8080'\n"), 0600))
+	origEnvFile := viper.GetString("env-file")
+	defer viper.Set("env-file", origEnvFile)
+	viper.Set("env-file", envPath)
+
+	var out, stderr bytes.Buffer
+	importCmd.SetOut(&out)
+	importCmd.SetErr(&stderr)
+	defer func() {
+		importFormat, importService, importOnConflict = "", "", conflictFail
+		importCmd.SetIn(nil)
+		importCmd.SetOut(nil)
+		importCmd.SetErr(nil)
+	}()
+
+	jsonPath := filepath.Join(tempDir, "config.json")
+	assert.NoError(t, ioutil.WriteFile(jsonPath, []byte(`{"PORT": 8080, "HOST": "db.internal", "PASS": "p@ss word$1", "bad key": "x"}`), 0600))
+
+	// Conflicts stop the import by default
+	err = importCmd.RunE(importCmd, []string{jsonPath})
+	assert.EqualError(t, err, "already set to other values in "+envPath+": HOST; use --on-conflict keep or overwrite")
+	content, err := ioutil.ReadFile(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "# app\nHOST=localhost\nPORT='8080'\n", string(content))
+
+	importOnConflict = conflictKeep
+	assert.NoError(t, importCmd.RunE(importCmd, []string{jsonPath}))
+	assert.Equal(t, envPath+": 1 added, 0 overwritten, 1 kept\n", out.String())
+	assert.Contains(t, stderr.String(), `envtool: skipping "bad key": not a valid key name`)
+	content, err = ioutil.ReadFile(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "# app\nHOST=localhost\nPORT='8080'\nPASS='p@ss word$1'\n", string(content))
+
+	importOnConflict = conflictOverwrite
+	out.Reset()
+	assert.NoError(t, importCmd.RunE(importCmd, []string{jsonPath}))
+	assert.Equal(t, envPath+": 0 added, 1 overwritten, 0 kept\n", out.String())
+	content, err = ioutil.ReadFile(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "# app\nHOST=db.internal\nPORT='8080'\nPASS='p@ss word$1'\n", string(content))
+
+	// Formats that cannot be told from the name need --format
+	importCmd.SetIn(strings.NewReader("EXTRA=\"a \\\"b\\\"\"\n"))
+	assert.EqualError(t, importCmd.RunE(importCmd, []string{"-"}), "cannot tell the format of stdin; use --format json|yaml|compose|systemd|environ")
+	importFormat = "systemd"
+	importCmd.SetIn(strings.NewReader("EXTRA=\"a \\\"b\\\"\"\n"))
+	assert.NoError(t, importCmd.RunE(importCmd, []string{}))
+
+	// env -0 output is recognized by its NUL separators
+	importFormat = ""
+	importCmd.SetIn(strings.NewReader("LINES=one\ntwo\x00"))
+	assert.NoError(t, importCmd.RunE(importCmd, []string{}))
+
+	content, err = ioutil.ReadFile(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "# app\nHOST=db.internal\nPORT='8080'\nPASS='p@ss word$1'\nEXTRA='a \"b\"'\nLINES=\"one\\ntwo\"\n", string(content))
+
+	importOnConflict = "ask"
+	assert.EqualError(t, importCmd.RunE(importCmd, []string{jsonPath}), `unknown conflict policy "ask"; use keep, overwrite or prompt-free-fail`)
+}
+
+func TestImportCmd_Compose(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-import-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	envPath := filepath.Join(tempDir, ".env")
+	origEnvFile := viper.GetString("env-file")
+	defer viper.Set("env-file", origEnvFile)
+	viper.Set("env-file", envPath)
+	importCmd.SetOut(ioutil.Discard)
+	defer func() {
+		importService = ""
+		importCmd.SetOut(nil)
+	}()
+
+	composePath := filepath.Join(tempDir, "docker-compose.yml")
+	compose := "services:\n" +
+		"  web:\n" +
+		"    environment:\n" +
+		"      - API_URL=http://api:$${PORT}\n" +
+		"  db:\n" +
+		"    environment:\n" +
+		"      POSTGRES_DB: app\n"
+	assert.NoError(t, ioutil.WriteFile(composePath, []byte(compose), 0600))
+
+	assert.EqualError(t, importCmd.RunE(importCmd, []string{composePath}), composePath+": choose a service: db, web")
+	importService = "web"
+	assert.NoError(t, importCmd.RunE(importCmd, []string{composePath}))
+	content, err := ioutil.ReadFile(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "API_URL='http://api:${PORT}'\n", string(content))
+}
+
+func TestImportCmd_PID(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads /proc")
+	}
+	tempDir, err := ioutil.TempDir("", "envtool-import-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	envPath := filepath.Join(tempDir, ".env")
+	origEnvFile := viper.GetString("env-file")
+	defer viper.Set("env-file", origEnvFile)
+	viper.Set("env-file", envPath)
+	importCmd.SetOut(ioutil.Discard)
+	importCmd.SetErr(ioutil.Discard)
+	defer func() {
+		importPID = 0
+		importCmd.SetOut(nil)
+		importCmd.SetErr(nil)
+	}()
+
+	// The environment the test process started with
+	importPID = os.Getpid()
+	assert.NoError(t, importCmd.RunE(importCmd, []string{}))
+	assert.EqualError(t, importCmd.RunE(importCmd, []string{"file.json"}), "give either a file or --pid")
+	content, err := ioutil.ReadFile(envPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "PATH=")
+}
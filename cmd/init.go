@@ -2,27 +2,49 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/username/envtool/pkg/emitter"
 	"github.com/username/envtool/pkg/shell"
 )
 
 // Default paths for shell configuration files
 const (
-	defaultBashrcPath = "/etc/bash.bashrc"
-	defaultZshrcPath  = "/etc/zsh/zshrc"
+	defaultBashrcPath   = "/etc/bash.bashrc"
+	defaultZshrcPath    = "/etc/zsh/zshrc"
+	defaultFishConfPath = "/etc/fish/conf.d/envtool.fish"
+	// PowerShell's AllUsersAllHosts profile on Linux
+	defaultPwshProfilePath = "/opt/microsoft/powershell/7/profile.ps1"
 )
 
+// hookVersion is recorded in the marker line of installed hooks. Bump it
+// whenever a hook template changes, so that init --check reports the hooks
+// installed by earlier versions as stale.
+const hookVersion = 2
+
 var (
-	bashrcPath string
-	zshrcPath  string
-	userOnly   bool
-	bashOnly   bool
-	zshOnly    bool
+	bashrcPath   string
+	zshrcPath    string
+	fishConfPath string
+	pwshProfile  string
+	nuConfigPath string
+	userOnly     bool
+	bashOnly     bool
+	zshOnly      bool
+	fishOnly     bool
+	pwshOnly     bool
+	nuOnly       bool
+	initCheck    bool
+	initDryRun   bool
+	initPrint    bool
+	initBackup   bool
 )
 
 // initCmd represents the init command
@@ -37,102 +59,251 @@ By default, it modifies system-wide configuration files. Use --user flag
 to modify user-specific configuration files instead.
 
 You can also specify custom paths for bash and zsh configuration files
-using the --bashrc and --zshrc flags.`,
+using the --bashrc and --zshrc flags.
+
+Bash and zsh are updated unless shells are selected with --bash, --zsh,
+--fish, --pwsh or --nu. Fish hooks are installed as conf.d/envtool.fish,
+in the path given by --fish-conf. PowerShell hooks go to the profile given
+by --pwsh-profile. Nushell has no system-wide configuration, so --nu needs
+--user or an explicit --nu-config.
+
+The hook is wrapped in "# BEGIN envtool" and "# END envtool" marker lines,
+which envtool uninit uses to remove it again. The begin marker records the
+hook version and the options the hook was built with. Running init again
+replaces an existing block in place, as well as a hook installed by
+earlier versions without markers. With --check, nothing is written;
+init reports whether each file's hook is missing, up to date or stale,
+and fails unless all are up to date.
+
+--dry-run prints a unified diff of the changes init would make to each
+file instead of writing them. --print writes only the hook block for a
+single selected shell to stdout, for use in configuration management.
+
+Files are replaced atomically, keeping their mode, owner and symlinks.
+With --backup, each changed file is first copied to
+FILE.<timestamp>.envtool.bak.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		fileManager := &shell.DefaultFileManager{}
 
-		// Determine which shells to update
-		updateBash := true
-		updateZsh := true
-		if bashOnly && !zshOnly {
-			updateZsh = false
-		}
-		if zshOnly && !bashOnly {
-			updateBash = false
+		targets, envPathFromArgs, err := selectHookTargets(args)
+		if err != nil {
+			return err
 		}
 
-		// If user passed positional args but selected both shells, return error
-		if len(args) > 0 && updateBash && updateZsh {
-			return fmt.Errorf("positional rc/env paths are supported only when selecting exactly one shell with --bash or --zsh")
+		// Determine env-files to embed in hook
+		envPathsForHook := []string{}
+		if value := strings.TrimSpace(envPathFromArgs); value != "" {
+			envPathsForHook = append(envPathsForHook, value)
+		} else {
+			// fall back to flag/config if set
+			for _, value := range viper.GetStringSlice("env-file") {
+				if value = strings.TrimSpace(value); value != "" {
+					envPathsForHook = append(envPathsForHook, value)
+				}
+			}
+			if len(envPathsForHook) == 1 && envPathsForHook[0] == ".env" {
+				envPathsForHook = nil
+			}
+		}
+		blocks := make([]string, len(targets))
+		for i, target := range targets {
+			envFlag, err := envFileArgs(target.Shell, envPathsForHook)
+			if err != nil {
+				return err
+			}
+			blocks[i] = shell.HookBlock(hookHeader(target.Shell, envPathsForHook), hookScript(target.Shell, envFlag))
 		}
 
-		// Optional env-file to embed in hook
-		envPathFromArgs := ""
+		if initPrint {
+			if len(targets) != 1 {
+				return fmt.Errorf("--print needs exactly one shell selected with --bash, --zsh, --fish, --pwsh or --nu")
+			}
+			fmt.Fprint(cmd.OutOrStdout(), strings.TrimPrefix(blocks[0], "\n"))
+			return nil
+		}
 
-		// If user-only flag is set, use user-specific config files
-		if userOnly {
-			homeDir, err := os.UserHomeDir()
-			if err == nil && homeDir != "" {
-				// Only override if not explicitly set via flags
-				if bashrcPath == defaultBashrcPath {
-					bashrcPath = filepath.Join(homeDir, ".bashrc")
-				}
-				if zshrcPath == defaultZshrcPath {
-					zshrcPath = filepath.Join(homeDir, ".zshrc")
+		if initDryRun {
+			for i, target := range targets {
+				changed, err := diffHook(cmd.OutOrStdout(), fileManager, target.Name, target.Path, blocks[i])
+				if err != nil {
+					return err
 				}
-			} else {
-				// HOME is unavailable; require explicit rc paths in --user mode
-				if bashOnly && !zshOnly {
-					if bashrcPath == defaultBashrcPath {
-						return fmt.Errorf("--user requires HOME or explicit --bashrc when HOME is unset")
-					}
-				} else if zshOnly && !bashOnly {
-					if zshrcPath == defaultZshrcPath {
-						return fmt.Errorf("--user requires HOME or explicit --zshrc when HOME is unset")
-					}
-				} else {
-					// Both shells selected; require at least one explicit path
-					if bashrcPath == defaultBashrcPath && zshrcPath == defaultZshrcPath {
-						return fmt.Errorf("--user requires HOME or explicit --bashrc/--zshrc paths when HOME is unset")
-					}
+				if !changed {
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s: %s is up to date\n", target.Name, target.Path)
 				}
 			}
+			return nil
 		}
 
-		// Handle positional args for exactly one shell
-		if updateBash && !updateZsh {
-			if len(args) >= 1 {
-				bashrcPath = args[0]
-			}
-			if len(args) >= 2 {
-				envPathFromArgs = args[1]
+		if initCheck {
+			stale := 0
+			fmt.Printf("Shell configurations:\n")
+			for i, target := range targets {
+				status, ok, err := hookStatus(fileManager, target.Name, target.Path, blocks[i])
+				if err != nil {
+					return err
+				}
+				if !ok {
+					stale++
+				}
+				fmt.Printf("- %s: %s: %s\n", target.Name, target.Path, status)
 			}
-		} else if updateZsh && !updateBash {
-			if len(args) >= 1 {
-				zshrcPath = args[0]
+			if stale > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d of %d hook(s) missing or stale; run envtool init to update them", stale, len(targets))
 			}
-			if len(args) >= 2 {
-				envPathFromArgs = args[1]
+			return nil
+		}
+
+		results := make([]string, len(targets))
+		for i, target := range targets {
+			result, err := installHook(fileManager, target.Name, target.Path, blocks[i], initBackup)
+			if err != nil {
+				return err
 			}
+			results[i] = result
 		}
 
-		// Determine env-file to embed in hook
-		envPathForHook := strings.TrimSpace(envPathFromArgs)
-		if envPathForHook == "" {
-			// fall back to flag/config if set
-			if value := strings.TrimSpace(viper.GetString("env-file")); value != "" && value != ".env" {
-				envPathForHook = value
+		fmt.Printf("Shell configurations updated successfully:\n")
+		for i, target := range targets {
+			fmt.Printf("- %s: %s (%s)\n", target.Name, target.Path, results[i])
+		}
+		return nil
+	},
+}
+
+// hookTarget is a shell configuration file that holds an envtool hook
+type hookTarget struct {
+	// Name is the shell name shown to the user
+	Name string
+	// Shell is the shell type passed to envtool env
+	Shell string
+	Path  string
+}
+
+// selectHookTargets works out which configuration files to act on from the
+// shell selection flags, --user and the positional args, which may name the
+// rc file and the env file of a single selected shell. It returns the
+// targets and the env file given in args, if any.
+func selectHookTargets(args []string) ([]hookTarget, string, error) {
+	// Determine which shells to update; bash and zsh by default
+	defaultShells := !bashOnly && !zshOnly && !fishOnly && !pwshOnly && !nuOnly
+	updateBash := bashOnly || defaultShells
+	updateZsh := zshOnly || defaultShells
+	updateFish := fishOnly
+	updatePwsh := pwshOnly
+	updateNu := nuOnly
+
+	// If user passed positional args but selected several shells, return error
+	selected := 0
+	for _, update := range []bool{updateBash, updateZsh, updateFish, updatePwsh, updateNu} {
+		if update {
+			selected++
+		}
+	}
+	if len(args) > 0 && selected > 1 {
+		return nil, "", fmt.Errorf("positional rc/env paths are supported only when selecting exactly one shell with --bash, --zsh, --fish, --pwsh or --nu")
+	}
+
+	// If user-only flag is set, use user-specific config files
+	if userOnly {
+		homeDir, err := os.UserHomeDir()
+		if err == nil && homeDir != "" {
+			// Only override if not explicitly set via flags
+			if bashrcPath == defaultBashrcPath {
+				bashrcPath = filepath.Join(homeDir, ".bashrc")
+			}
+			if zshrcPath == defaultZshrcPath {
+				zshrcPath = filepath.Join(homeDir, ".zshrc")
+			}
+			configDir := os.Getenv("XDG_CONFIG_HOME")
+			if configDir == "" {
+				configDir = filepath.Join(homeDir, ".config")
+			}
+			if fishConfPath == defaultFishConfPath {
+				fishConfPath = filepath.Join(configDir, "fish", "conf.d", "envtool.fish")
+			}
+			if pwshProfile == defaultPwshProfilePath {
+				pwshProfile = filepath.Join(configDir, "powershell", "Microsoft.PowerShell_profile.ps1")
+			}
+			if nuConfigPath == "" {
+				nuConfigPath = filepath.Join(configDir, "nushell", "config.nu")
+			}
+		} else {
+			// HOME is unavailable; require explicit rc paths in --user mode
+			if updateFish && fishConfPath == defaultFishConfPath {
+				return nil, "", fmt.Errorf("--user requires HOME or explicit --fish-conf when HOME is unset")
+			}
+			if updatePwsh && pwshProfile == defaultPwshProfilePath {
+				return nil, "", fmt.Errorf("--user requires HOME or explicit --pwsh-profile when HOME is unset")
+			}
+			if updateBash && !updateZsh {
+				if bashrcPath == defaultBashrcPath {
+					return nil, "", fmt.Errorf("--user requires HOME or explicit --bashrc when HOME is unset")
+				}
+			} else if updateZsh && !updateBash {
+				if zshrcPath == defaultZshrcPath {
+					return nil, "", fmt.Errorf("--user requires HOME or explicit --zshrc when HOME is unset")
+				}
+			} else if updateBash && updateZsh {
+				// Both shells selected; require at least one explicit path
+				if bashrcPath == defaultBashrcPath && zshrcPath == defaultZshrcPath {
+					return nil, "", fmt.Errorf("--user requires HOME or explicit --bashrc/--zshrc paths when HOME is unset")
+				}
 			}
 		}
-		envFlag := ""
-		if envPathForHook != "" {
-			envFlag = fmt.Sprintf(" --env-file %s", envPathForHook)
+	}
+
+	// Handle positional args for exactly one shell
+	if len(args) >= 1 {
+		switch {
+		case updateBash:
+			bashrcPath = args[0]
+		case updateZsh:
+			zshrcPath = args[0]
+		case updateFish:
+			fishConfPath = args[0]
+		case updatePwsh:
+			pwshProfile = args[0]
+		case updateNu:
+			nuConfigPath = args[0]
 		}
+	}
+	envPath := ""
+	if len(args) >= 2 {
+		envPath = args[1]
+	}
 
-		// Build hook contents dynamically
-		bashHook := fmt.Sprintf(`
-_envtool_hook() {
-  local previous_exit_status=$?;
-  trap -- '' SIGINT;
-  eval "$(envtool env bash%s)";
-  trap - SIGINT;
-  return $previous_exit_status;
-};
-if ! [[ "${PROMPT_COMMAND:-}" =~ _envtool_hook ]]; then
-  PROMPT_COMMAND="_envtool_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
-fi
-`, envFlag)
-		zshHook := fmt.Sprintf(`
+	if updateNu && nuConfigPath == "" {
+		return nil, "", fmt.Errorf("--nu requires --user or an explicit --nu-config path")
+	}
+
+	var targets []hookTarget
+	if updateBash {
+		targets = append(targets, hookTarget{Name: "Bash", Shell: "bash", Path: bashrcPath})
+	}
+	if updateZsh {
+		targets = append(targets, hookTarget{Name: "Zsh", Shell: "zsh", Path: zshrcPath})
+	}
+	if updateFish {
+		targets = append(targets, hookTarget{Name: "Fish", Shell: "fish", Path: fishConfPath})
+	}
+	if updatePwsh {
+		targets = append(targets, hookTarget{Name: "PowerShell", Shell: "pwsh", Path: pwshProfile})
+	}
+	if updateNu {
+		targets = append(targets, hookTarget{Name: "Nushell", Shell: "nu", Path: nuConfigPath})
+	}
+	return targets, envPath, nil
+}
+
+// hookScript builds the hook for a shell type. envFlag holds the
+// --env-file arguments to pass to envtool env, already quoted for the
+// shell.
+func hookScript(shellType, envFlag string) string {
+	switch shellType {
+	case "zsh":
+		return fmt.Sprintf(`
 _envtool_hook() {
   trap -- '' SIGINT;
   eval "$(envtool env zsh%s)";
@@ -147,63 +318,234 @@ if [[ -z "${chpwd_functions[(r)_envtool_hook]+1}" ]]; then
   chpwd_functions=( _envtool_hook ${chpwd_functions[@]} )
 fi
 `, envFlag)
+	case "fish":
+		return fmt.Sprintf(`
+function _envtool_hook --on-variable PWD --on-event fish_prompt
+  envtool env fish%s | source
+end
+`, envFlag)
+	case "pwsh":
+		return fmt.Sprintf(`
+function global:_envtool_hook {
+  $output = envtool env pwsh%s | Out-String
+  if ($output.Trim()) { Invoke-Expression $output }
+}
+if ($global:_envtool_hooked -ne 1) {
+  $global:_envtool_hooked = 1
+  $global:_envtool_prompt_old = $function:prompt
+  function global:prompt {
+    $null = _envtool_hook
+    if ($null -ne $global:_envtool_prompt_old) { & $global:_envtool_prompt_old }
+  }
+}
+`, envFlag)
+	case "nu":
+		return fmt.Sprintf(`
+$env.config = ($env.config | upsert hooks.pre_prompt (
+  ($env.config.hooks?.pre_prompt? | default []) | append {||
+    let output = (^envtool env nu%s | str trim)
+    let changes = if ($output | is-empty) { {unset: [], set: {}} } else { $output | from json }
+    hide-env -i ...$changes.unset
+    load-env $changes.set
+  }
+))
+`, envFlag)
+	}
+	return fmt.Sprintf(`
+_envtool_hook() {
+  local previous_exit_status=$?;
+  trap -- '' SIGINT;
+  eval "$(envtool env bash%s)";
+  trap - SIGINT;
+  return $previous_exit_status;
+};
+if ! [[ "${PROMPT_COMMAND:-}" =~ _envtool_hook ]]; then
+  PROMPT_COMMAND="_envtool_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`, envFlag)
+}
 
-		// Setup for bash
-		if updateBash {
-			exists, err := fileManager.FileExists(bashrcPath)
-			if err != nil {
-				return fmt.Errorf("failed to check bash configuration: %w", err)
-			}
-
-			// Create parent directory if it doesn't exist
-			if !exists {
-				dir := filepath.Dir(bashrcPath)
-				if err := os.MkdirAll(dir, 0755); err != nil {
-					return fmt.Errorf("failed to create directory for bash configuration: %w", err)
-				}
-
-				if err := fileManager.WriteFile(bashrcPath, ""); err != nil {
-					return fmt.Errorf("failed to create bash configuration: %w", err)
-				}
-			}
+// envFileArgs builds the --env-file arguments passed to envtool env in the
+// hook, each path quoted for the shell the hook runs in
+func envFileArgs(shellType string, envPaths []string) (string, error) {
+	em, err := emitter.ForShell(shellType)
+	if err != nil {
+		return "", err
+	}
+	args := ""
+	for _, envPath := range envPaths {
+		args += " --env-file " + em.Quote(envPath)
+	}
+	return args, nil
+}
 
-			if err := fileManager.AppendToFile(bashrcPath, bashHook); err != nil {
-				return fmt.Errorf("failed to update bash configuration: %w", err)
-			}
+// hookHeader describes a hook in its begin marker line: the hook version
+// and the options the hook was built with. Paths that are not plain words
+// are written as Go string literals, so the header stays on one line.
+func hookHeader(shellType string, envPaths []string) string {
+	header := fmt.Sprintf("v%d shell=%s", hookVersion, shellType)
+	for _, envPath := range envPaths {
+		if strings.ContainsAny(envPath, " '") || strconv.Quote(envPath) != `"`+envPath+`"` {
+			envPath = strconv.Quote(envPath)
 		}
+		header += " env-file=" + envPath
+	}
+	return header
+}
 
-		// Setup for zsh
-		if updateZsh {
-			exists, err := fileManager.FileExists(zshrcPath)
-			if err != nil {
-				return fmt.Errorf("failed to check zsh configuration: %w", err)
-			}
-
-			if !exists {
-				dir := filepath.Dir(zshrcPath)
-				if err := os.MkdirAll(dir, 0755); err != nil {
-					return fmt.Errorf("failed to create directory for zsh configuration: %w", err)
-				}
+// hookEdit is the change installing a hook makes to a configuration file
+type hookEdit struct {
+	Exists  bool
+	Content string
+	Updated string
+	// Replaced is set if Content already held a hook, marked or legacy
+	Replaced bool
+}
 
-				if err := fileManager.WriteFile(zshrcPath, ""); err != nil {
-					return fmt.Errorf("failed to create zsh configuration: %w", err)
-				}
-			}
+// planHook works out the content of a shell configuration file once the
+// hook block is installed. An existing envtool block, or a legacy hook
+// installed without markers, is replaced in place.
+func planHook(fileManager shell.FileManager, shellName, path, block string) (hookEdit, error) {
+	exists, err := fileManager.FileExists(path)
+	if err != nil {
+		return hookEdit{}, fmt.Errorf("failed to check %s configuration: %w", shellName, err)
+	}
 
-			if err := fileManager.AppendToFile(zshrcPath, zshHook); err != nil {
-				return fmt.Errorf("failed to update zsh configuration: %w", err)
-			}
+	edit := hookEdit{Exists: exists}
+	if exists {
+		if edit.Content, err = fileManager.ReadFile(path); err != nil {
+			return hookEdit{}, fmt.Errorf("failed to read %s configuration: %w", shellName, err)
 		}
+	}
+	blocks, err := shell.FindHookBlocks(edit.Content)
+	if err != nil {
+		return hookEdit{}, fmt.Errorf("%s: %w", path, err)
+	}
+	edit.Replaced = len(blocks) > 0
+	if edit.Updated, err = shell.ReplaceHookBlocks(edit.Content, block); err != nil {
+		return hookEdit{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return edit, nil
+}
 
-		fmt.Printf("Shell configurations updated successfully:\n")
-		if updateBash {
-			fmt.Printf("- Bash: %s\n", bashrcPath)
+// installHook writes a hook block to a shell configuration file, creating
+// the file and its parent directory if needed. An existing envtool block is
+// replaced in place. With backup, a changed file's previous content is
+// saved first. It returns what was done: "installed", "updated" or "up to
+// date", and where the backup went.
+func installHook(fileManager shell.FileManager, shellName, path, block string, backup bool) (string, error) {
+	edit, err := planHook(fileManager, shellName, path, block)
+	if err != nil {
+		return "", err
+	}
+	if edit.Exists && edit.Updated == edit.Content {
+		return "up to date", nil
+	}
+
+	// Create parent directory if it doesn't exist
+	if !edit.Exists {
+		dir := filepath.Dir(path)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create directory for %s configuration: %w", shellName, err)
 		}
-		if updateZsh {
-			fmt.Printf("- Zsh: %s\n", zshrcPath)
+	}
+
+	backupNote := ""
+	if backup && edit.Exists {
+		backupPath, err := fileManager.BackupFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to back up %s configuration: %w", shellName, err)
 		}
+		backupNote = ", backup in " + backupPath
+	}
+
+	if err := fileManager.WriteFile(path, edit.Updated); err != nil {
+		return "", fmt.Errorf("failed to update %s configuration: %w", shellName, err)
+	}
+	if edit.Replaced {
+		return "updated" + backupNote, nil
+	}
+	return "installed" + backupNote, nil
+}
+
+// diffHook writes a unified diff of the change installing a hook block
+// would make to a shell configuration file. Missing files are shown as
+// /dev/null. It reports whether the file would change.
+func diffHook(out io.Writer, fileManager shell.FileManager, shellName, path, block string) (bool, error) {
+	edit, err := planHook(fileManager, shellName, path, block)
+	if err != nil {
+		return false, err
+	}
+	if edit.Exists && edit.Updated == edit.Content {
+		return false, nil
+	}
+
+	fromFile := path
+	if !edit.Exists {
+		fromFile = "/dev/null"
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        diffLines(edit.Content),
+		B:        diffLines(edit.Updated),
+		FromFile: fromFile,
+		ToFile:   path,
+		Context:  3,
+	})
+	if err != nil {
+		return false, err
+	}
+	fmt.Fprint(out, diff)
+	return true, nil
+}
+
+// diffLines splits text into lines for diffing, each ending in a newline
+func diffLines(text string) []string {
+	if text == "" {
 		return nil
-	},
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+// hookStatus compares the hook in a shell configuration file with block.
+// It returns "missing", "up to date" or "stale", and whether the hook is
+// up to date.
+func hookStatus(fileManager shell.FileManager, shellName, path, block string) (string, bool, error) {
+	exists, err := fileManager.FileExists(path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to check %s configuration: %w", shellName, err)
+	}
+	if !exists {
+		return "missing (file not found)", false, nil
+	}
+	content, err := fileManager.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s configuration: %w", shellName, err)
+	}
+	blocks, err := shell.FindHookBlocks(content)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", path, err)
+	}
+
+	switch {
+	case len(blocks) == 0:
+		return "missing", false, nil
+	case len(blocks) > 1:
+		return fmt.Sprintf("stale (%d hook blocks)", len(blocks)), false, nil
+	case blocks[0].Legacy:
+		return fmt.Sprintf("stale (legacy hook without markers at line %d)", blocks[0].Line), false, nil
+	case blocks[0].Text != strings.TrimPrefix(block, "\n"):
+		installed := blocks[0].Header
+		if installed == "" {
+			installed = "unversioned"
+		}
+		return fmt.Sprintf("stale (installed: %s)", installed), false, nil
+	}
+	return "up to date", true, nil
 }
 
 func init() {
@@ -212,9 +554,19 @@ func init() {
 	// Add flags for customizing configuration paths
 	initCmd.Flags().StringVar(&bashrcPath, "bashrc", defaultBashrcPath, "Path to bash configuration file")
 	initCmd.Flags().StringVar(&zshrcPath, "zshrc", defaultZshrcPath, "Path to zsh configuration file")
+	initCmd.Flags().StringVar(&fishConfPath, "fish-conf", defaultFishConfPath, "Path to fish conf.d hook file")
+	initCmd.Flags().StringVar(&pwshProfile, "pwsh-profile", defaultPwshProfilePath, "Path to PowerShell profile")
+	initCmd.Flags().StringVar(&nuConfigPath, "nu-config", "", "Path to nushell config.nu (default with --user: ~/.config/nushell/config.nu)")
 	initCmd.Flags().BoolVar(&userOnly, "user", false, "Modify user-specific configuration files instead of system-wide")
 	initCmd.Flags().BoolVar(&bashOnly, "bash", false, "Only update bash configuration (default: both shells)")
 	initCmd.Flags().BoolVar(&zshOnly, "zsh", false, "Only update zsh configuration (default: both shells)")
+	initCmd.Flags().BoolVar(&fishOnly, "fish", false, "Update fish configuration (not included by default)")
+	initCmd.Flags().BoolVar(&pwshOnly, "pwsh", false, "Update PowerShell profile (not included by default)")
+	initCmd.Flags().BoolVar(&nuOnly, "nu", false, "Update nushell configuration (not included by default)")
+	initCmd.Flags().BoolVar(&initDryRun, "dry-run", false, "Print a unified diff of the changes instead of writing them")
+	initCmd.Flags().BoolVar(&initPrint, "print", false, "Print only the hook for the selected shell to stdout")
+	initCmd.Flags().BoolVar(&initBackup, "backup", false, "Save a timestamped .envtool.bak copy of each file before changing it")
+	initCmd.Flags().BoolVar(&initCheck, "check", false, "Report whether installed hooks are missing, up to date or stale without changing anything")
 
 	// Bind to viper for config file support
 	viper.BindPFlag("init.bashrc", initCmd.Flags().Lookup("bashrc"))
@@ -222,4 +574,10 @@ func init() {
 	viper.BindPFlag("init.user", initCmd.Flags().Lookup("user"))
 	viper.BindPFlag("init.bash", initCmd.Flags().Lookup("bash"))
 	viper.BindPFlag("init.zsh", initCmd.Flags().Lookup("zsh"))
+	viper.BindPFlag("init.fish-conf", initCmd.Flags().Lookup("fish-conf"))
+	viper.BindPFlag("init.fish", initCmd.Flags().Lookup("fish"))
+	viper.BindPFlag("init.pwsh-profile", initCmd.Flags().Lookup("pwsh-profile"))
+	viper.BindPFlag("init.pwsh", initCmd.Flags().Lookup("pwsh"))
+	viper.BindPFlag("init.nu-config", initCmd.Flags().Lookup("nu-config"))
+	viper.BindPFlag("init.nu", initCmd.Flags().Lookup("nu"))
 }
@@ -1,14 +1,91 @@
 package cmd
 
 import (
+	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/username/envtool/pkg/shell"
 )
 
+// fakeFileManager keeps files in memory
+type fakeFileManager struct {
+	files    map[string]string
+	backups  int
+	writeErr error
+}
+
+func newFakeFileManager() *fakeFileManager {
+	return &fakeFileManager{files: map[string]string{}}
+}
+
+func (fm *fakeFileManager) AppendToFile(path, content string) error {
+	if fm.writeErr != nil {
+		return fm.writeErr
+	}
+	fm.files[path] += content
+	return nil
+}
+
+func (fm *fakeFileManager) ReadFile(path string) (string, error) {
+	content, ok := fm.files[path]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+	return content, nil
+}
+
+func (fm *fakeFileManager) FileExists(path string) (bool, error) {
+	_, ok := fm.files[path]
+	return ok, nil
+}
+
+func (fm *fakeFileManager) WriteFile(path, content string) error {
+	if fm.writeErr != nil {
+		return fm.writeErr
+	}
+	fm.files[path] = content
+	return nil
+}
+
+func (fm *fakeFileManager) BackupFile(path string) (string, error) {
+	if fm.writeErr != nil {
+		return "", fm.writeErr
+	}
+	fm.backups++
+	backupPath := fmt.Sprintf("%s.bak%d", path, fm.backups)
+	fm.files[backupPath] = fm.files[path]
+	return backupPath, nil
+}
+
+func TestInstallHook_FakeFileManager(t *testing.T) {
+	fm := newFakeFileManager()
+	fm.files["/etc/bash.bashrc"] = "before\n"
+	block := shell.HookBlock("v1 shell=bash", "hook")
+
+	result, err := installHook(fm, "Bash", "/etc/bash.bashrc", block, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "installed, backup in /etc/bash.bashrc.bak1", result)
+	assert.Equal(t, "before\n"+block, fm.files["/etc/bash.bashrc"])
+	assert.Equal(t, "before\n", fm.files["/etc/bash.bashrc.bak1"])
+
+	// No change, no backup
+	result, err = installHook(fm, "Bash", "/etc/bash.bashrc", block, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "up to date", result)
+	assert.Equal(t, 1, fm.backups)
+
+	result, err = installHook(fm, "Bash", "/etc/bash.bashrc", shell.HookBlock("v2 shell=bash", "hook"), false)
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", result)
+	assert.Equal(t, 1, fm.backups)
+}
+
 func TestInitCmd_CustomPaths(t *testing.T) {
 	// Create a temporary directory for test files
 	tempDir, err := ioutil.TempDir("", "envtool-test")
@@ -104,7 +181,7 @@ func TestInitCmd_BashOnly_PositionalPaths(t *testing.T) {
 	assert.NoError(t, err)
 	text := string(content)
 	assert.Contains(t, text, "envtool env bash")
-	assert.Contains(t, text, "--env-file "+envPath)
+	assert.Contains(t, text, "--env-file '"+envPath+"'")
 	assert.NotContains(t, text, "envtool env zsh")
 }
 
@@ -149,6 +226,374 @@ func TestInitCmd_ZshOnly_PositionalPaths(t *testing.T) {
 	assert.NoError(t, err)
 	text := string(content)
 	assert.Contains(t, text, "envtool env zsh")
-	assert.Contains(t, text, "--env-file "+envPath)
+	assert.Contains(t, text, "--env-file '"+envPath+"'")
 	assert.NotContains(t, text, "envtool env bash")
-}
\ No newline at end of file
+}
+
+func TestInitCmd_Fish(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-test-fish")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Save originals
+	origBashrc := bashrcPath
+	origZshrc := zshrcPath
+	origFishConf := fishConfPath
+	origUserOnly := userOnly
+	origBashOnly := bashOnly
+	origZshOnly := zshOnly
+	origFishOnly := fishOnly
+	defer func() {
+		bashrcPath = origBashrc
+		zshrcPath = origZshrc
+		fishConfPath = origFishConf
+		userOnly = origUserOnly
+		bashOnly = origBashOnly
+		zshOnly = origZshOnly
+		fishOnly = origFishOnly
+	}()
+
+	// Configure: fish only, in --user mode with a custom config home
+	origHome := os.Getenv("HOME")
+	origConfigHome := os.Getenv("XDG_CONFIG_HOME")
+	defer os.Setenv("HOME", origHome)
+	defer os.Setenv("XDG_CONFIG_HOME", origConfigHome)
+	os.Setenv("HOME", tempDir)
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "config"))
+
+	fishConfPath = defaultFishConfPath
+	bashrcPath = defaultBashrcPath
+	zshrcPath = defaultZshrcPath
+	userOnly = true
+	bashOnly = false
+	zshOnly = false
+	fishOnly = true
+
+	cmd := initCmd
+	err = cmd.RunE(cmd, []string{})
+	assert.NoError(t, err)
+
+	confPath := filepath.Join(tempDir, "config", "fish", "conf.d", "envtool.fish")
+	content, err := ioutil.ReadFile(confPath)
+	assert.NoError(t, err)
+	text := string(content)
+	assert.Contains(t, text, "function _envtool_hook --on-variable PWD --on-event fish_prompt")
+	assert.Contains(t, text, "envtool env fish | source")
+
+	// Bash and zsh are left alone
+	_, err = os.Stat(filepath.Join(tempDir, ".bashrc"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(tempDir, ".zshrc"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestEnvFileArgs_Quoting(t *testing.T) {
+	envPath := "/home/me/my project/it's.env"
+	tests := []struct {
+		shellType string
+		expected  string
+	}{
+		{"bash", ` --env-file '/home/me/my project/it'"'"'s.env'`},
+		{"zsh", ` --env-file '/home/me/my project/it'"'"'s.env'`},
+		{"fish", ` --env-file '/home/me/my project/it\'s.env'`},
+		{"pwsh", ` --env-file '/home/me/my project/it''s.env'`},
+		{"nu", ` --env-file "/home/me/my project/it's.env"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shellType, func(t *testing.T) {
+			args, err := envFileArgs(tt.shellType, []string{envPath})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, args)
+		})
+	}
+
+	// The header keeps such a path on the marker line
+	assert.Equal(t, `v2 shell=bash env-file="/home/me/my project/it's.env" env-file=".env\nrm -rf ~"`,
+		hookHeader("bash", []string{envPath, ".env\nrm -rf ~"}))
+}
+
+func TestInitCmd_PositionalArgsNeedOneShell(t *testing.T) {
+	origBashOnly := bashOnly
+	origZshOnly := zshOnly
+	origFishOnly := fishOnly
+	defer func() {
+		bashOnly = origBashOnly
+		zshOnly = origZshOnly
+		fishOnly = origFishOnly
+	}()
+
+	bashOnly = true
+	zshOnly = false
+	fishOnly = true
+
+	cmd := initCmd
+	err := cmd.RunE(cmd, []string{"/tmp/rc"})
+	assert.Error(t, err)
+}
+
+func TestInitCmd_PwshAndNu(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-test-pwsh-nu")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Save originals
+	origPwshProfile := pwshProfile
+	origNuConfig := nuConfigPath
+	origUserOnly := userOnly
+	origBashOnly := bashOnly
+	origZshOnly := zshOnly
+	origFishOnly := fishOnly
+	origPwshOnly := pwshOnly
+	origNuOnly := nuOnly
+	defer func() {
+		pwshProfile = origPwshProfile
+		nuConfigPath = origNuConfig
+		userOnly = origUserOnly
+		bashOnly = origBashOnly
+		zshOnly = origZshOnly
+		fishOnly = origFishOnly
+		pwshOnly = origPwshOnly
+		nuOnly = origNuOnly
+	}()
+
+	origHome := os.Getenv("HOME")
+	origConfigHome := os.Getenv("XDG_CONFIG_HOME")
+	defer os.Setenv("HOME", origHome)
+	defer os.Setenv("XDG_CONFIG_HOME", origConfigHome)
+	os.Setenv("HOME", tempDir)
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "config"))
+
+	// Without --user, nushell has no default config to update
+	pwshProfile = defaultPwshProfilePath
+	nuConfigPath = ""
+	userOnly = false
+	bashOnly = false
+	zshOnly = false
+	fishOnly = false
+	pwshOnly = false
+	nuOnly = true
+	err = initCmd.RunE(initCmd, []string{})
+	assert.Error(t, err)
+
+	// Configure: PowerShell and nushell, in --user mode
+	userOnly = true
+	pwshOnly = true
+	err = initCmd.RunE(initCmd, []string{})
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(tempDir, "config", "powershell", "Microsoft.PowerShell_profile.ps1"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "function global:_envtool_hook")
+	assert.Contains(t, string(content), "envtool env pwsh | Out-String")
+
+	content, err = ioutil.ReadFile(filepath.Join(tempDir, "config", "nushell", "config.nu"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "hooks.pre_prompt")
+	assert.Contains(t, string(content), "^envtool env nu | str trim")
+	assert.Contains(t, string(content), "from json")
+
+	// Bash and zsh are left alone
+	_, err = os.Stat(filepath.Join(tempDir, ".bashrc"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestInitCmd_CheckAndUpgrade(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-test-check")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	bashrc := filepath.Join(tempDir, "bashrc")
+
+	// Save originals
+	origBashrc := bashrcPath
+	origUserOnly := userOnly
+	origBashOnly := bashOnly
+	origZshOnly := zshOnly
+	origCheck := initCheck
+	defer func() {
+		bashrcPath = origBashrc
+		userOnly = origUserOnly
+		bashOnly = origBashOnly
+		zshOnly = origZshOnly
+		initCheck = origCheck
+	}()
+
+	bashrcPath = bashrc
+	userOnly = false
+	bashOnly = true
+	zshOnly = false
+
+	// Missing file
+	initCheck = true
+	assert.Error(t, initCmd.RunE(initCmd, []string{}))
+
+	// A hook from an older version, with user content around it
+	old := "before\n\n# BEGIN envtool\nold hook\n# END envtool\nafter\n"
+	assert.NoError(t, ioutil.WriteFile(bashrc, []byte(old), 0644))
+	assert.Error(t, initCmd.RunE(initCmd, []string{}))
+
+	// init replaces the old block in place
+	initCheck = false
+	assert.NoError(t, initCmd.RunE(initCmd, []string{}))
+	content, err := ioutil.ReadFile(bashrc)
+	assert.NoError(t, err)
+	text := string(content)
+	assert.True(t, strings.HasPrefix(text, "before\n\n# BEGIN envtool v2 shell=bash\n"), text)
+	assert.True(t, strings.HasSuffix(text, "# END envtool\nafter\n"), text)
+	assert.NotContains(t, text, "old hook")
+	assert.Equal(t, 1, strings.Count(text, "# BEGIN envtool"))
+
+	// Running again is a no-op, and the hook is reported up to date
+	assert.NoError(t, initCmd.RunE(initCmd, []string{}))
+	content, err = ioutil.ReadFile(bashrc)
+	assert.NoError(t, err)
+	assert.Equal(t, text, string(content))
+
+	initCheck = true
+	assert.NoError(t, initCmd.RunE(initCmd, []string{}))
+
+	// Building the hook with different options makes it stale
+	assert.Error(t, initCmd.RunE(initCmd, []string{bashrc, "other.env"}))
+}
+
+func TestInitCmd_UpgradeLegacyHook(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-test-legacy")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	bashrc := filepath.Join(tempDir, "bashrc")
+
+	// Save originals
+	origBashrc := bashrcPath
+	origUserOnly := userOnly
+	origBashOnly := bashOnly
+	origZshOnly := zshOnly
+	origCheck := initCheck
+	defer func() {
+		bashrcPath = origBashrc
+		userOnly = origUserOnly
+		bashOnly = origBashOnly
+		zshOnly = origZshOnly
+		initCheck = origCheck
+	}()
+
+	bashrcPath = bashrc
+	userOnly = false
+	bashOnly = true
+	zshOnly = false
+
+	// The hook appended by envtool init before hooks had markers
+	legacy := `alias ll='ls -l'
+
+_envtool_hook() {
+  local previous_exit_status=$?;
+  trap -- '' SIGINT;
+  eval "$(envtool env bash --env-file .env.local)";
+  trap - SIGINT;
+  return $previous_exit_status;
+};
+if ! [[ "${PROMPT_COMMAND:-}" =~ _envtool_hook ]]; then
+  PROMPT_COMMAND="_envtool_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+export EDITOR=vi
+`
+	assert.NoError(t, ioutil.WriteFile(bashrc, []byte(legacy), 0644))
+
+	status, ok, err := hookStatus(&shell.DefaultFileManager{}, "Bash", bashrc, shell.HookBlock("v1 shell=bash", "hook"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "stale (legacy hook without markers at line 3)", status)
+
+	initCheck = true
+	assert.Error(t, initCmd.RunE(initCmd, []string{}))
+
+	// init replaces the legacy hook in place rather than adding a second one
+	initCheck = false
+	assert.NoError(t, initCmd.RunE(initCmd, []string{}))
+	content, err := ioutil.ReadFile(bashrc)
+	assert.NoError(t, err)
+	text := string(content)
+	assert.True(t, strings.HasPrefix(text, "alias ll='ls -l'\n\n# BEGIN envtool v2 shell=bash\n"), text)
+	assert.True(t, strings.HasSuffix(text, "# END envtool\nexport EDITOR=vi\n"), text)
+	assert.Equal(t, 1, strings.Count(text, "_envtool_hook() {"))
+	assert.NotContains(t, text, ".env.local")
+
+	initCheck = true
+	assert.NoError(t, initCmd.RunE(initCmd, []string{}))
+}
+
+func TestInitCmd_DryRunAndPrint(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-test-dry-run")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	bashrc := filepath.Join(tempDir, "bashrc")
+	assert.NoError(t, ioutil.WriteFile(bashrc, []byte("alias ll='ls -l'\n"), 0644))
+	zshrc := filepath.Join(tempDir, "zsh", "zshrc")
+
+	// Save originals
+	origBashrc := bashrcPath
+	origZshrc := zshrcPath
+	origUserOnly := userOnly
+	origBashOnly := bashOnly
+	origZshOnly := zshOnly
+	origDryRun := initDryRun
+	origPrint := initPrint
+	defer func() {
+		bashrcPath = origBashrc
+		zshrcPath = origZshrc
+		userOnly = origUserOnly
+		bashOnly = origBashOnly
+		zshOnly = origZshOnly
+		initDryRun = origDryRun
+		initPrint = origPrint
+		initCmd.SetOut(nil)
+	}()
+
+	bashrcPath = bashrc
+	zshrcPath = zshrc
+	userOnly = false
+	bashOnly = false
+	zshOnly = false
+	initDryRun = true
+	initPrint = false
+
+	var out bytes.Buffer
+	initCmd.SetOut(&out)
+	assert.NoError(t, initCmd.RunE(initCmd, []string{}))
+	diff := out.String()
+	assert.Contains(t, diff, "--- "+bashrc+"\n+++ "+bashrc+"\n@@ -1 +1,14 @@\n alias ll='ls -l'\n+\n+# BEGIN envtool v2 shell=bash\n")
+	assert.Contains(t, diff, "--- /dev/null\n+++ "+zshrc+"\n@@ -0,0 +1,15 @@\n+# BEGIN envtool v2 shell=zsh\n")
+
+	// Nothing is written
+	content, err := ioutil.ReadFile(bashrc)
+	assert.NoError(t, err)
+	assert.Equal(t, "alias ll='ls -l'\n", string(content))
+	_, err = os.Stat(filepath.Dir(zshrc))
+	assert.True(t, os.IsNotExist(err))
+
+	// --print needs a single shell
+	initDryRun = false
+	initPrint = true
+	assert.Error(t, initCmd.RunE(initCmd, []string{}))
+
+	out.Reset()
+	zshOnly = true
+	assert.NoError(t, initCmd.RunE(initCmd, []string{zshrc, "app.env"}))
+	printed := out.String()
+	assert.True(t, strings.HasPrefix(printed, "# BEGIN envtool v2 shell=zsh env-file=app.env\n"), printed)
+	assert.Contains(t, printed, `eval "$(envtool env zsh --env-file 'app.env')"`)
+	assert.True(t, strings.HasSuffix(printed, "# END envtool\n"), printed)
+	_, err = os.Stat(filepath.Dir(zshrc))
+	assert.True(t, os.IsNotExist(err))
+}
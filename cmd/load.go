@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/username/envtool/pkg/envfile"
+	"github.com/username/envtool/pkg/resolve"
+)
+
+// loadOptions controls how loadEnvFiles evaluates the .env files
+type loadOptions struct {
+	// evaluate runs $(...) commands, if they are enabled, and resolves
+	// secret references; otherwise values are kept as written
+	evaluate bool
+	// force ignores the cached output of commands
+	force bool
+	// cache holds the references resolved by earlier runs, if any
+	cache resolve.Cache
+	// check rejects names the consumer cannot take, besides those the
+	// policy blocks; nil accepts every name
+	check func(key string) error
+}
+
+// loadEnvFiles loads the configured .env files the way envtool env does:
+// untrusted files are skipped, the rest are decrypted, parsed and merged,
+// schema defaults are applied if enabled, and variables the policy or
+// opts.check reject are dropped. Problems with
+// single files or variables are reported on stderr, and the variables they
+// concern are left out. It also returns the files the commands that ran
+// watch, joined like $PATH.
+func loadEnvFiles(opts loadOptions, stderr io.Writer) (*envfile.Merged, string, error) {
+	// Get paths to .env files, in merge order
+	paths, err := envFilePaths()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Only load files the user has allowed
+	store, err := loadTrustStore()
+	if err != nil {
+		return nil, "", err
+	}
+	paths = trustedFiles(store, paths, stderr)
+
+	// Parse and merge .env files, decrypting them in memory; missing
+	// files are skipped
+	parser := &envfile.DefaultParser{ReadDocument: loadKeyring().ReadDocument}
+	var runner *commandRunner
+	if opts.evaluate {
+		if runner, err = newCommandRunner(store, opts.force); err != nil {
+			fmt.Fprintln(stderr, "envtool:", err)
+		} else if runner != nil {
+			parser.RunCommand = runner.Run
+		}
+	}
+	merged, err := parser.ParseFiles(paths)
+	if diags, ok := err.(envfile.Diagnostics); ok {
+		// Load what could be parsed, but say what was skipped
+		for _, d := range diags {
+			fmt.Fprintln(stderr, "envtool:", d)
+		}
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	// Keep the output of commands for the next runs
+	watch := ""
+	if runner != nil {
+		if err := runner.Save(); err != nil {
+			fmt.Fprintln(stderr, "envtool: saving command cache:", err)
+		}
+		watch = runner.Watched()
+	}
+
+	// Fill in the defaults of the schema
+	applySchemaDefaults(merged, store, stderr)
+
+	// Drop variables the policy does not allow, and names the consumer
+	// cannot take
+	pol, err := loadPolicy()
+	if err != nil {
+		return nil, "", err
+	}
+	dropVariables(merged, pol.Check, stderr)
+	if opts.check != nil {
+		dropVariables(merged, opts.check, stderr)
+	}
+
+	// Replace secret references with the secrets they point to
+	if opts.evaluate {
+		resolveReferences(merged, newResolver(opts.cache, runner), stderr)
+	}
+	return merged, watch, nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/spf13/viper"
+	"github.com/username/envtool/pkg/emitter"
+	"github.com/username/envtool/pkg/envfile"
+	"github.com/username/envtool/pkg/policy"
+)
+
+// loadPolicy builds the variable policy from the policy.deny, policy.allow
+// and policy.name-pattern settings
+func loadPolicy() (*policy.Policy, error) {
+	return policy.New(
+		viper.GetStringSlice("policy.deny"),
+		viper.GetStringSlice("policy.allow"),
+		viper.GetString("policy.name-pattern"),
+	)
+}
+
+// dropVariables removes the variables check rejects from merged, with a
+// warning pointing at the line that set each of them. Warnings are ordered
+// by file and line.
+func dropVariables(merged *envfile.Merged, check func(key string) error, stderr io.Writer) {
+	var blocked []string
+	reasons := make(map[string]error)
+	for key := range merged.Values {
+		if err := check(key); err != nil {
+			blocked = append(blocked, key)
+			reasons[key] = err
+		}
+	}
+
+	fileIndex := make(map[string]int, len(merged.Files))
+	for i, file := range merged.Files {
+		fileIndex[file] = i
+	}
+	sort.Slice(blocked, func(i, j int) bool {
+		a, b := merged.Origins[blocked[i]], merged.Origins[blocked[j]]
+		if a.File != b.File {
+			return fileIndex[a.File] < fileIndex[b.File]
+		}
+		return a.Line < b.Line
+	})
+
+	for _, key := range blocked {
+		origin := merged.Origins[key]
+		fmt.Fprintf(stderr, "envtool: %s:%d: warning: not setting %v\n", origin.File, origin.Line, reasons[key])
+		delete(merged.Values, key)
+		delete(merged.Origins, key)
+	}
+}
+
+// validKeyCheck rejects names the target shell cannot use as variables
+func validKeyCheck(em emitter.Emitter, shellType string) func(string) error {
+	return func(key string) error {
+		if !em.ValidKey(key) {
+			return fmt.Errorf("%q is not a valid %s variable name", key, shellType)
+		}
+		return nil
+	}
+}
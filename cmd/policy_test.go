@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvCmd_Policy(t *testing.T) {
+	content := "APP_PORT=8080\nLD_PRELOAD=/tmp/evil.so\nENVTOOL_MANAGED_ENV_VARS=PATH\nAWS_PROFILE=prod\n"
+	inTempProject(t, content, func(dir string) {
+		defer viper.Set("policy.deny", nil)
+		viper.Set("policy.deny", []string{"AWS_*"})
+
+		var out bytes.Buffer
+		envCmd.SetOut(&out)
+		stderr := captureStderr(t, func() {
+			assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		})
+		assert.Equal(t, "envtool: .env:2: warning: not setting LD_PRELOAD is denied by policy (LD_PRELOAD)\n"+
+			"envtool: .env:3: warning: not setting ENVTOOL_MANAGED_ENV_VARS is reserved for envtool\n"+
+			"envtool: .env:4: warning: not setting AWS_PROFILE is denied by policy (AWS_*)\n", stderr)
+		assert.Contains(t, out.String(), "export APP_PORT='8080'\n")
+		assert.Contains(t, out.String(), "export ENVTOOL_MANAGED_ENV_VARS='APP_PORT'\n")
+		assert.NotContains(t, out.String(), "LD_PRELOAD")
+		assert.NotContains(t, out.String(), "AWS_PROFILE")
+	})
+}
+
+func TestEnvCmd_PolicyInvalid(t *testing.T) {
+	inTempProject(t, "APP_PORT=8080\n", func(dir string) {
+		defer viper.Set("policy.name-pattern", "")
+		viper.Set("policy.name-pattern", "(")
+
+		var out bytes.Buffer
+		envCmd.SetOut(&out)
+		stderr := captureStderr(t, func() {
+			assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		})
+		assert.Contains(t, stderr, "invalid policy name pattern")
+		assert.Equal(t, "", out.String())
+	})
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/username/envtool/pkg/envfile"
+	"github.com/username/envtool/pkg/resolve"
+)
+
+// resolveDefaults are the timeouts and cache lifetimes of the built-in
+// providers. Reading a file or a variable is cheap enough to do every
+// time; commands and Vault are not.
+var resolveDefaults = map[string]resolve.Options{
+	"file":  {Timeout: time.Second},
+	"env":   {},
+	"cmd":   {Timeout: 5 * time.Second, TTL: 5 * time.Minute},
+	"vault": {Timeout: 5 * time.Second, TTL: 5 * time.Minute},
+}
+
+// newResolver returns a resolver with the built-in providers. ref+cmd://
+// references are run by runner, under the same rules as $(...)
+// substitutions; without a runner, they fail. The
+// resolve.<provider>.timeout and resolve.<provider>.ttl settings override
+// the defaults.
+func newResolver(cache resolve.Cache, runner *commandRunner) *resolve.Resolver {
+	r := resolve.NewResolver()
+	r.Cache = cache
+	var commands resolve.Provider = resolve.ProviderFunc(func(ctx context.Context, ref resolve.Ref) (string, error) {
+		return "", fmt.Errorf("commands are disabled; set commands.enabled to run them")
+	})
+	if runner != nil {
+		commands = runner
+	}
+	providers := map[string]resolve.Provider{
+		"file":  resolve.File{},
+		"env":   resolve.Env{},
+		"cmd":   commands,
+		"vault": vaultProvider(),
+	}
+	for scheme, p := range providers {
+		opts := resolveDefaults[scheme]
+		if key := "resolve." + scheme + ".timeout"; viper.IsSet(key) {
+			opts.Timeout = viper.GetDuration(key)
+		}
+		if key := "resolve." + scheme + ".ttl"; viper.IsSet(key) {
+			opts.TTL = viper.GetDuration(key)
+		}
+		r.Register(scheme, p, opts)
+	}
+	return r
+}
+
+// vaultProvider configures the Vault provider like the vault CLI: from
+// $VAULT_ADDR, or the resolve.vault.addr setting, $VAULT_TOKEN or
+// ~/.vault-token, and $VAULT_NAMESPACE
+func vaultProvider() *resolve.Vault {
+	v := &resolve.Vault{
+		Addr:      os.Getenv("VAULT_ADDR"),
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+	}
+	if addr := viper.GetString("resolve.vault.addr"); addr != "" {
+		v.Addr = addr
+	}
+	if v.Token == "" {
+		if home, err := os.UserHomeDir(); err == nil && home != "" {
+			if data, err := ioutil.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+				v.Token = strings.TrimSpace(string(data))
+			}
+		}
+	}
+	return v
+}
+
+// resolveReferences replaces the references among the merged values with
+// the secrets they point to. Variables whose reference cannot be resolved
+// are left out with a warning, like blocked variables.
+func resolveReferences(merged *envfile.Merged, r *resolve.Resolver, stderr io.Writer) {
+	keys := make([]string, 0, len(merged.Values))
+	for key := range merged.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	failed := map[string]error{}
+	for _, key := range keys {
+		value := merged.Values[key]
+		resolved, err := r.Resolve(context.Background(), key, value, merged.Origins[key].File)
+		if err != nil {
+			failed[key] = fmt.Errorf("%s: cannot resolve %s: %v", key, value, err)
+			continue
+		}
+		merged.Values[key] = resolved
+	}
+	dropVariables(merged, func(key string) error { return failed[key] }, stderr)
+}
+
+// resolvedCache is a resolve.Cache kept in the shell environment, in
+// ENVTOOL_RESOLVED. Resolved values are exported anyway, so only digests
+// of each reference and value are kept, with the time it was resolved. A
+// value is reused while the variable still holds it.
+type resolvedCache struct {
+	entries   map[string]resolvedEntry
+	next      map[string]resolvedEntry
+	lookupEnv func(string) (string, bool)
+	now       func() time.Time
+}
+
+// resolvedEntry records how a variable was resolved
+type resolvedEntry struct {
+	Ref   string `json:"r"`
+	Value string `json:"v"`
+	At    int64  `json:"t"`
+}
+
+// loadResolvedCache decodes a cache saved by Encode. A malformed cache is
+// treated as empty.
+func loadResolvedCache(encoded string, lookupEnv func(string) (string, bool), now func() time.Time) *resolvedCache {
+	c := &resolvedCache{
+		entries:   map[string]resolvedEntry{},
+		next:      map[string]resolvedEntry{},
+		lookupEnv: lookupEnv,
+		now:       now,
+	}
+	if data, err := base64.RawURLEncoding.DecodeString(encoded); err == nil && encoded != "" {
+		if err := json.Unmarshal(data, &c.entries); err != nil {
+			c.entries = map[string]resolvedEntry{}
+		}
+	}
+	return c
+}
+
+// Get returns the current value of key if it was resolved from ref less
+// than maxAge ago and has not been changed since
+func (c *resolvedCache) Get(key, ref string, maxAge time.Duration) (string, bool) {
+	e, ok := c.entries[key]
+	if !ok || e.Ref != digest(ref) || c.now().Sub(time.Unix(e.At, 0)) >= maxAge {
+		return "", false
+	}
+	value, ok := c.lookupEnv(key)
+	if !ok || digest(value) != e.Value {
+		return "", false
+	}
+	c.next[key] = e
+	return value, true
+}
+
+// Put records that key was just resolved from ref to value
+func (c *resolvedCache) Put(key, ref, value string) {
+	c.next[key] = resolvedEntry{Ref: digest(ref), Value: digest(value), At: c.now().Unix()}
+}
+
+// Encode returns the entries used by this run, for the next one, or ""
+// if there are none
+func (c *resolvedCache) Encode() string {
+	if len(c.next) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(c.next)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// digest returns a short hash of s
+func digest(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
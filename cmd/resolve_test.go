@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolvedCache(t *testing.T) {
+	now := time.Unix(1000, 0)
+	environ := map[string]string{"TOKEN": "secret"}
+	lookupEnv := func(key string) (string, bool) {
+		value, ok := environ[key]
+		return value, ok
+	}
+	clock := func() time.Time { return now }
+
+	c := loadResolvedCache("", lookupEnv, clock)
+	_, ok := c.Get("TOKEN", "ref+cmd://x", time.Minute)
+	assert.False(t, ok)
+	assert.Equal(t, "", c.Encode())
+	c.Put("TOKEN", "ref+cmd://x", "secret")
+	encoded := c.Encode()
+	assert.NotContains(t, encoded, "secret")
+
+	now = now.Add(30 * time.Second)
+	c = loadResolvedCache(encoded, lookupEnv, clock)
+	value, ok := c.Get("TOKEN", "ref+cmd://x", time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, "secret", value)
+	// Entries that were used are kept for the next run
+	assert.Equal(t, encoded, c.Encode())
+
+	_, ok = c.Get("TOKEN", "ref+cmd://y", time.Minute)
+	assert.False(t, ok, "another reference")
+	_, ok = c.Get("TOKEN", "ref+cmd://x", 30*time.Second)
+	assert.False(t, ok, "expired")
+	_, ok = c.Get("OTHER", "ref+cmd://x", time.Minute)
+	assert.False(t, ok, "another variable")
+	environ["TOKEN"] = "changed"
+	_, ok = c.Get("TOKEN", "ref+cmd://x", time.Minute)
+	assert.False(t, ok, "changed value")
+
+	// Unused entries are dropped, and malformed caches are empty
+	c = loadResolvedCache(encoded, lookupEnv, clock)
+	assert.Equal(t, "", c.Encode())
+	c = loadResolvedCache("not base64!", lookupEnv, clock)
+	assert.Empty(t, c.entries)
+}
+
+// exported returns the value a line of Posix output exports for key
+func exported(output, key string) string {
+	m := regexp.MustCompile(`(?m)^export ` + key + `='([^']*)'$`).FindStringSubmatch(output)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+func TestEnvCmd_References(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/app" || r.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"password":"from-vault"},"metadata":{}}}`))
+	}))
+	defer vault.Close()
+	viper.Set("resolve.vault.addr", vault.URL)
+	defer viper.Set("resolve.vault.addr", "")
+	viper.Set("commands.enabled", true)
+	defer viper.Set("commands.enabled", nil)
+	origToken, hadToken := os.LookupEnv("VAULT_TOKEN")
+	os.Setenv("VAULT_TOKEN", "test-token")
+	os.Setenv("ENVTOOL_TEST_SOURCE", "from-env")
+	defer func() {
+		if hadToken {
+			os.Setenv("VAULT_TOKEN", origToken)
+		} else {
+			os.Unsetenv("VAULT_TOKEN")
+		}
+		os.Unsetenv("ENVTOOL_TEST_SOURCE")
+		os.Unsetenv("CMD_SECRET")
+		os.Unsetenv(ResolvedKey)
+	}()
+
+	content := "FILE_SECRET=ref+file://secret.txt\n" +
+		"ENV_SECRET=ref+env://ENVTOOL_TEST_SOURCE\n" +
+		"CMD_SECRET='ref+cmd://echo run >> calls; printf from-cmd'\n" +
+		"VAULT_SECRET=ref+vault://secret/data/app#password\n" +
+		"BROKEN=ref+file://missing.txt\n" +
+		"PLAIN=ref+unknown\n"
+	inTempProject(t, content, func(dir string) {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "secret.txt"), []byte("from-file\n"), 0600))
+		calls := func() int {
+			data, _ := ioutil.ReadFile(filepath.Join(dir, "calls"))
+			return strings.Count(string(data), "run")
+		}
+
+		var out bytes.Buffer
+		envCmd.SetOut(&out)
+		stderr := captureStderr(t, func() {
+			assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		})
+		output := out.String()
+		assert.Equal(t, "from-file", exported(output, "FILE_SECRET"))
+		assert.Equal(t, "from-env", exported(output, "ENV_SECRET"))
+		assert.Equal(t, "from-cmd", exported(output, "CMD_SECRET"))
+		assert.Equal(t, "from-vault", exported(output, "VAULT_SECRET"))
+		assert.Equal(t, "ref+unknown", exported(output, "PLAIN"))
+		assert.NotContains(t, output, "BROKEN")
+		assert.Contains(t, stderr, "envtool: .env:5: warning: not setting BROKEN: cannot resolve ref+file://missing.txt: file: open ")
+		assert.Equal(t, 1, calls())
+
+		// The next run reuses the command's output while the shell still
+		// holds it
+		os.Setenv("CMD_SECRET", "from-cmd")
+		os.Setenv(ResolvedKey, exported(output, ResolvedKey))
+		out.Reset()
+		captureStderr(t, func() {
+			assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		})
+		assert.Equal(t, "from-cmd", exported(out.String(), "CMD_SECRET"))
+		assert.Equal(t, 1, calls())
+
+		// --force resolves every reference again
+		envForce = true
+		defer func() { envForce = false }()
+		out.Reset()
+		captureStderr(t, func() {
+			assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		})
+		assert.Equal(t, "from-cmd", exported(out.String(), "CMD_SECRET"))
+		assert.Equal(t, 2, calls())
+	})
+}
+
+func TestEnvCmd_CommandReferences(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	os.Setenv("ENVTOOL_TEST_HIDDEN", "leaked")
+	defer func() {
+		os.Unsetenv("ENVTOOL_TEST_HIDDEN")
+		os.Unsetenv(ResolvedKey)
+		viper.Set("commands.enabled", nil)
+	}()
+
+	content := "CMD_SECRET='ref+cmd://echo run >> calls; printf \"s3cret-${ENVTOOL_TEST_HIDDEN:-hidden}\"'\n"
+	inTempProject(t, content, func(dir string) {
+		// Like $(...), ref+cmd:// references only run with commands enabled
+		var out bytes.Buffer
+		envCmd.SetOut(&out)
+		stderr := captureStderr(t, func() {
+			assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		})
+		assert.NotContains(t, out.String(), "CMD_SECRET")
+		assert.Contains(t, stderr, "cmd: commands are disabled; set commands.enabled to run them")
+		_, err := os.Stat(filepath.Join(dir, "calls"))
+		assert.True(t, os.IsNotExist(err))
+
+		// They see the restricted environment, and their output never
+		// reaches the command cache on disk
+		viper.Set("commands.enabled", true)
+		out.Reset()
+		captureStderr(t, func() {
+			assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		})
+		assert.Equal(t, "s3cret-hidden", exported(out.String(), "CMD_SECRET"))
+		path, err := commandCachePath()
+		assert.NoError(t, err)
+		data, _ := ioutil.ReadFile(path)
+		assert.NotContains(t, string(data), "s3cret")
+	})
+}
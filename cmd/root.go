@@ -9,8 +9,14 @@ import (
 )
 
 var (
-	cfgFile string
-	envFile string
+	cfgFile      string
+	envFiles     []string
+	preset       string
+	environment  string
+	walk         bool
+	walkBoundary string
+	walkMarkers  []string
+	verbose      bool
 )
 
 // rootCmd represents the base command
@@ -34,10 +40,27 @@ func init() {
 
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.envtool.yaml)")
-	rootCmd.PersistentFlags().StringVar(&envFile, "env-file", ".env", "path to .env file")
+	rootCmd.PersistentFlags().StringSliceVar(&envFiles, "env-file", []string{".env"}, "path to .env file; repeat to layer files, later ones overriding earlier ones")
+	rootCmd.PersistentFlags().StringVar(&preset, "preset", "", "file layout preset: layered loads FILE, FILE.local, FILE.<environment> and FILE.<environment>.local")
+	rootCmd.PersistentFlags().StringVar(&environment, "environment", "", "environment selected by the layered preset (default $ENVTOOL_ENV)")
+	rootCmd.PersistentFlags().BoolVar(&walk, "walk", false, "also load .env files from parent directories, nearest file winning")
+	rootCmd.PersistentFlags().StringVar(&walkBoundary, "walk-boundary", "home", "last directory searched by --walk: home, root or a path")
+	rootCmd.PersistentFlags().StringSliceVar(&walkMarkers, "walk-marker", nil, "stop --walk at a directory containing one of these names (e.g. .git)")
+	rootCmd.PersistentFlags().String("schema", "", "schema file describing the variables (default .env.schema)")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "report the config file in use on stderr")
 
 	// Bind flags to viper
 	viper.BindPFlag("env-file", rootCmd.PersistentFlags().Lookup("env-file"))
+	viper.BindPFlag("preset", rootCmd.PersistentFlags().Lookup("preset"))
+	viper.BindPFlag("environment", rootCmd.PersistentFlags().Lookup("environment"))
+	viper.BindEnv("environment", "ENVTOOL_ENV")
+	viper.BindPFlag("walk", rootCmd.PersistentFlags().Lookup("walk"))
+	viper.BindPFlag("walk-boundary", rootCmd.PersistentFlags().Lookup("walk-boundary"))
+	viper.BindPFlag("walk-marker", rootCmd.PersistentFlags().Lookup("walk-marker"))
+	viper.BindPFlag("schema.file", rootCmd.PersistentFlags().Lookup("schema"))
+	viper.BindEnv("trust-db", "ENVTOOL_TRUST_DB")
+	viper.BindEnv("age-identity", "ENVTOOL_AGE_IDENTITY")
+	viper.BindEnv("command-cache", "ENVTOOL_COMMAND_CACHE")
 }
 
 // initConfig reads in config file and ENV variables if set
@@ -54,8 +77,9 @@ func initConfig() {
 
 	viper.AutomaticEnv() // read in environment variables that match
 
-	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+	// If a config file is found, read it in. Stdout is kept for the
+	// output of commands, which the hooks evaluate.
+	if err := viper.ReadInConfig(); err == nil && verbose {
+		fmt.Fprintln(rootCmd.ErrOrStderr(), "Using config file:", viper.ConfigFileUsed())
 	}
 }
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/username/envtool/pkg/envfile"
+	"github.com/username/envtool/pkg/resolve"
+	"github.com/username/envtool/pkg/schema"
+	"github.com/username/envtool/pkg/trust"
+)
+
+// schemaPath returns the schema file: the schema.file setting or --schema,
+// or .env.schema in the current directory
+func schemaPath() string {
+	if path := viper.GetString("schema.file"); path != "" {
+		return path
+	}
+	return schema.DefaultName
+}
+
+// loadSchema reads the schema file, or returns nil if there is none
+func loadSchema() (*schema.Schema, error) {
+	s, err := schema.Load(schemaPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return s, err
+}
+
+// applySchemaDefaults sets the variables merged lacks to their defaults
+// from the schema, if the schema.defaults setting is on. Like .env files,
+// the schema must be trusted for its defaults to be used.
+func applySchemaDefaults(merged *envfile.Merged, store *trust.Store, stderr io.Writer) {
+	if !viper.GetBool("schema.defaults") {
+		return
+	}
+	path := schemaPath()
+	if _, err := os.Stat(path); err != nil || len(trustedFiles(store, []string{path}, stderr)) == 0 {
+		return
+	}
+	s, err := loadSchema()
+	if err != nil {
+		fmt.Fprintln(stderr, "envtool:", err)
+		return
+	}
+	s.ApplyDefaults(merged)
+}
+
+// uncheckedValue reports values whose type cannot be checked before they
+// are loaded: secret references and command substitutions
+func uncheckedValue(value string) bool {
+	return resolve.IsReference(value) || strings.Contains(value, "$(")
+}
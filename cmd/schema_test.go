@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSchema = `PORT:
+  type: port
+  default: 8080
+LOG_LEVEL:
+  type: enum
+  values: [debug, info]
+  default: info
+DATABASE_URL:
+  type: url
+  required: true
+  secret: true
+`
+
+func TestCheckCmd_Schema(t *testing.T) {
+	inTempProject(t, "PORT=http\nDATABASE_URL=ref+file://db.txt\nEXTRA=1\n", func(dir string) {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".env.schema"), []byte(testSchema), 0644))
+		var stderr bytes.Buffer
+		checkCmd.SetErr(&stderr)
+		defer checkCmd.SetErr(nil)
+
+		err := checkCmd.RunE(checkCmd, []string{})
+		assert.EqualError(t, err, "found 1 error(s) and 1 warning(s)")
+		assert.Equal(t, ".env:1:1: error: PORT: \"http\" is not a valid port\n"+
+			".env:3:1: warning: EXTRA is not in the schema .env.schema\n", stderr.String())
+
+		// Required variables must be set
+		assert.NoError(t, ioutil.WriteFile(".env", []byte("PORT=80\n"), 0644))
+		stderr.Reset()
+		assert.Error(t, checkCmd.RunE(checkCmd, []string{}))
+		assert.Equal(t, ".env.schema:8:1: error: DATABASE_URL is required but not set\n", stderr.String())
+
+		// --schema names another file
+		viper.Set("schema.file", filepath.Join(dir, "missing.schema"))
+		defer viper.Set("schema.file", "")
+		stderr.Reset()
+		assert.NoError(t, checkCmd.RunE(checkCmd, []string{}))
+		assert.Empty(t, stderr.String())
+
+		assert.NoError(t, ioutil.WriteFile("broken.schema", []byte("PORT:\n  type: number\n"), 0644))
+		viper.Set("schema.file", "broken.schema")
+		assert.EqualError(t, checkCmd.RunE(checkCmd, []string{}), `broken.schema:1: PORT: unknown type "number"`)
+	})
+}
+
+func TestEnvCmd_SchemaDefaults(t *testing.T) {
+	inTempProject(t, "PORT=9000\n", func(dir string) {
+		schemaFile := filepath.Join(dir, ".env.schema")
+		assert.NoError(t, ioutil.WriteFile(schemaFile, []byte(testSchema), 0644))
+		var out bytes.Buffer
+		envCmd.SetOut(&out)
+
+		// Defaults are only applied when asked for
+		assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		assert.NotContains(t, out.String(), "LOG_LEVEL")
+
+		viper.Set("schema.defaults", true)
+		defer viper.Set("schema.defaults", false)
+
+		// and only from a trusted schema
+		out.Reset()
+		stderr := captureStderr(t, func() {
+			assert.NoError(t, envCmd.RunE(envCmd, []string{"bash"}))
+		})
+		assert.NotContains(t, out.String(), "LOG_LEVEL")
+		assert.Contains(t, stderr, ".env.schema is not trusted")
+
+		trustCommands, err := trustCommandPaths(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{".env", ".env.schema"}, trustCommands)
+		allowFiles(t, schemaFile)
+		out.Reset()
+		assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		assert.Equal(t, "info", exported(out.String(), "LOG_LEVEL"))
+		assert.Equal(t, "9000", exported(out.String(), "PORT"))
+		assert.NotContains(t, out.String(), "DATABASE_URL")
+
+		out.Reset()
+		envExplain = true
+		defer func() { envExplain = false }()
+		assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+		assert.Contains(t, out.String(), "LOG_LEVEL  .env.schema:4\n")
+	})
+}
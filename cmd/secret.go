@@ -0,0 +1,387 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/username/envtool/pkg/atomicfile"
+	"github.com/username/envtool/pkg/envfile"
+	"github.com/username/envtool/pkg/secret"
+)
+
+var recipientKeys []string
+
+// encryptCmd represents the encrypt command
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt [KEY...]",
+	Short: "Encrypt a .env file or some of its values",
+	Long: `Encrypt the .env file given by --env-file with age.
+
+Without arguments, the whole file is encrypted to FILE.enc, which envtool
+env loads right after FILE. The plain file is left in place; delete it or
+keep it out of version control. With keys, only their values are encrypted,
+in place, as ENC[age:...]. Values are encrypted as written, without their
+quotes; references in them are kept and expanded when the decrypted
+value is loaded, as they would be in the plain file.
+
+Data is encrypted to the --recipient public keys, or the age-recipients
+setting, or else to the public key of your identity file, which is created
+if it does not exist.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envFilePath, err := targetEnvFile()
+		if err != nil {
+			return err
+		}
+		if secret.IsEncryptedFile(envFilePath) {
+			return fmt.Errorf("%s is already encrypted", envFilePath)
+		}
+		recipients, err := encryptionRecipients(recipientKeys, cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 0 {
+			data, err := ioutil.ReadFile(envFilePath)
+			if err != nil {
+				return err
+			}
+			encrypted, err := secret.Encrypt(data, recipients)
+			if err != nil {
+				return err
+			}
+			if err := atomicfile.WriteFile(envFilePath+secret.Suffix, encrypted, 0600); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Encrypted %s to %s\n", envFilePath, envFilePath+secret.Suffix)
+			return nil
+		}
+
+		doc, err := envfile.ReadDocument(envFilePath)
+		if err != nil {
+			return err
+		}
+		for _, key := range args {
+			n := doc.Lookup(key)
+			if n == nil {
+				return fmt.Errorf("%s is not set in %s", key, envFilePath)
+			}
+			if secret.IsEncryptedValue(n.RawValue) {
+				continue
+			}
+			encrypted, err := secret.EncryptValue(n.LiteralValue(), recipients)
+			if err != nil {
+				return err
+			}
+			n.SetValue(encrypted)
+		}
+		return doc.WriteFile(envFilePath)
+	},
+}
+
+// decryptCmd represents the decrypt command
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt [file]",
+	Short: "Print the plaintext of an encrypted .env file",
+	Long: `Print the plaintext of an encrypted .env file, or of a .env file with
+ENC[age:...] values, to stdout. Nothing is written to disk.
+
+Without arguments, the encrypted variant of the file given by --env-file is
+decrypted if it exists, otherwise the file itself.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := encryptedTarget(args)
+		if err != nil {
+			return err
+		}
+		identities, err := loadKeyring().Identities()
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if secret.IsEncryptedFile(path) {
+			plaintext, err := secret.Decrypt(data, identities)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			_, err = cmd.OutOrStdout().Write(plaintext)
+			return err
+		}
+		doc := envfile.ParseDocument(path, data)
+		if _, err := decryptValues(doc, identities); err != nil {
+			return err
+		}
+		_, err = cmd.OutOrStdout().Write(doc.Bytes())
+		return err
+	},
+}
+
+// editCmd represents the edit command
+var editCmd = &cobra.Command{
+	Use:   "edit [file]",
+	Short: "Edit an encrypted .env file",
+	Long: `Decrypt a .env file, open it in $VISUAL or $EDITOR, and encrypt it again.
+
+Files ending in .enc are encrypted as a whole and may not exist yet. In
+other files, the values that were ENC[age:...] are encrypted again; values
+that were not changed keep their ciphertext. Without arguments, the
+encrypted variant of the file given by --env-file is edited if it exists,
+otherwise the file itself.
+
+The plaintext is kept in a private temporary directory, in memory-backed
+/dev/shm where available, and removed once the editor exits.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := encryptedTarget(args)
+		if err != nil {
+			return err
+		}
+		keyring := loadKeyring()
+		data, err := ioutil.ReadFile(path)
+		exists := err == nil
+		if err != nil && !(os.IsNotExist(err) && secret.IsEncryptedFile(path)) {
+			return err
+		}
+
+		var plaintext []byte
+		var encrypted map[string]encryptedValue
+		if exists {
+			identities, err := keyring.Identities()
+			if err != nil {
+				return err
+			}
+			if secret.IsEncryptedFile(path) {
+				if plaintext, err = secret.Decrypt(data, identities); err != nil {
+					return fmt.Errorf("%s: %w", path, err)
+				}
+			} else {
+				doc := envfile.ParseDocument(path, data)
+				if encrypted, err = decryptValues(doc, identities); err != nil {
+					return err
+				}
+				plaintext = doc.Bytes()
+			}
+		}
+
+		edited, err := editTemp(filepath.Base(strings.TrimSuffix(path, secret.Suffix)), plaintext)
+		if err != nil {
+			return err
+		}
+		if exists && bytes.Equal(edited, plaintext) {
+			fmt.Fprintf(cmd.OutOrStdout(), "No changes to %s\n", path)
+			return nil
+		}
+		recipients, err := encryptionRecipients(recipientKeys, cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+
+		if secret.IsEncryptedFile(path) {
+			data, err := secret.Encrypt(edited, recipients)
+			if err != nil {
+				return err
+			}
+			if err := atomicfile.WriteFile(path, data, 0600); err != nil {
+				return err
+			}
+		} else {
+			doc := envfile.ParseDocument(path, edited)
+			if err := encryptValues(doc, encrypted, recipients); err != nil {
+				return err
+			}
+			if err := doc.WriteFile(path); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Saved %s\n", path)
+		return nil
+	},
+}
+
+// identityPath returns the age identity file: the age-identity setting,
+// or $ENVTOOL_AGE_IDENTITY, or the default location
+func identityPath() (string, error) {
+	if path := viper.GetString("age-identity"); path != "" {
+		return path, nil
+	}
+	return secret.DefaultIdentityPath()
+}
+
+// loadKeyring returns a keyring for the identity file. A missing identity
+// is only reported once something needs to be decrypted.
+func loadKeyring() *secret.Keyring {
+	path, _ := identityPath()
+	return &secret.Keyring{IdentityFile: path}
+}
+
+// encryptionRecipients returns the recipients given with --recipient, else
+// those in the age-recipients setting, else the public key of the identity
+// file, which is created if it does not exist
+func encryptionRecipients(keys []string, stderr io.Writer) ([]age.Recipient, error) {
+	if len(keys) == 0 {
+		keys = viper.GetStringSlice("age-recipients")
+	}
+	if len(keys) > 0 {
+		return secret.ParseRecipients(keys)
+	}
+
+	path, err := identityPath()
+	if err != nil {
+		return nil, err
+	}
+	identities, err := secret.ReadIdentities(path)
+	if os.IsNotExist(err) {
+		identity, err := secret.GenerateIdentity(path)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(stderr, "envtool: created a new age identity in %s; back it up, it is needed to decrypt\n", path)
+		identities = []age.Identity{identity}
+	} else if err != nil {
+		return nil, err
+	}
+
+	recipients := secret.IdentityRecipients(identities)
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no X25519 identity in %s to encrypt to", path)
+	}
+	return recipients, nil
+}
+
+// encryptedTarget returns the file given to decrypt or edit, or the
+// encrypted variant of the --env-file target if it exists, or the target
+func encryptedTarget(args []string) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+	path, err := targetEnvFile()
+	if err != nil {
+		return "", err
+	}
+	if secret.IsEncryptedFile(path) {
+		return path, nil
+	}
+	if _, err := os.Stat(path + secret.Suffix); err == nil {
+		return path + secret.Suffix, nil
+	}
+	return path, nil
+}
+
+// withEncrypted adds the encrypted variant of each file name right after
+// it, so that FILE.enc overrides FILE
+func withEncrypted(names []string) []string {
+	var all []string
+	for _, name := range names {
+		all = append(all, name)
+		if !secret.IsEncryptedFile(name) {
+			all = append(all, name+secret.Suffix)
+		}
+	}
+	return all
+}
+
+// encryptedValue is an ENC[age:...] value and its plaintext
+type encryptedValue struct {
+	Ciphertext string
+	Plaintext  string
+}
+
+// decryptValues replaces the ENC[age:...] values of doc with their
+// plaintext and returns both, by key
+func decryptValues(doc *envfile.Document, identities []age.Identity) (map[string]encryptedValue, error) {
+	encrypted := map[string]encryptedValue{}
+	for _, n := range doc.Entries() {
+		if !secret.IsEncryptedValue(n.RawValue) {
+			continue
+		}
+		plaintext, err := secret.DecryptValue(n.RawValue, identities)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: cannot decrypt %s: %w", doc.Name, n.KeyPos.Line, n.Key, err)
+		}
+		encrypted[n.Key] = encryptedValue{Ciphertext: n.RawValue, Plaintext: plaintext}
+		n.SetLiteralValue(plaintext)
+	}
+	return encrypted, nil
+}
+
+// encryptValues encrypts the values of the keys in encrypted again,
+// keeping the old ciphertext of values that did not change
+func encryptValues(doc *envfile.Document, encrypted map[string]encryptedValue, recipients []age.Recipient) error {
+	for _, n := range doc.Entries() {
+		old, ok := encrypted[n.Key]
+		if !ok || secret.IsEncryptedValue(n.RawValue) {
+			continue
+		}
+		value := n.LiteralValue()
+		ciphertext := old.Ciphertext
+		if value != old.Plaintext {
+			var err error
+			if ciphertext, err = secret.EncryptValue(value, recipients); err != nil {
+				return err
+			}
+		}
+		n.SetValue(ciphertext)
+	}
+	return nil
+}
+
+// editTemp lets the user edit content in $VISUAL or $EDITOR and returns
+// the result. The content is written to a file called name in a private
+// temporary directory, preferably in memory-backed /dev/shm, which is
+// removed afterwards.
+func editTemp(name string, content []byte) ([]byte, error) {
+	base := ""
+	if info, err := os.Stat("/dev/shm"); err == nil && info.IsDir() {
+		base = "/dev/shm"
+	}
+	dir, err := ioutil.TempDir(base, "envtool-edit")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		return nil, err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	fields := strings.Fields(editor)
+	editorCmd := exec.Command(fields[0], append(fields[1:], path)...)
+	editorCmd.Stdin, editorCmd.Stdout, editorCmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := editorCmd.Run(); err != nil {
+		return nil, fmt.Errorf("running %s: %w", editor, err)
+	}
+	return ioutil.ReadFile(path)
+}
+
+func init() {
+	rootCmd.AddCommand(encryptCmd)
+	rootCmd.AddCommand(decryptCmd)
+	rootCmd.AddCommand(editCmd)
+
+	for _, cmd := range []*cobra.Command{encryptCmd, editCmd} {
+		cmd.Flags().StringSliceVarP(&recipientKeys, "recipient", "r", nil, "age public key to encrypt to; repeat for several recipients")
+	}
+}
@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/username/envtool/pkg/envfile"
+	"github.com/username/envtool/pkg/secret"
+)
+
+// withIdentity runs f with a fresh age identity path, which does not
+// exist until a command creates it
+func withIdentity(t *testing.T, dir string, f func(identity string)) {
+	identity := filepath.Join(dir, "keys", "identity.txt")
+	viper.Set("age-identity", identity)
+	defer viper.Set("age-identity", os.Getenv("ENVTOOL_AGE_IDENTITY"))
+	defer func() {
+		for _, cmd := range []*cobra.Command{encryptCmd, decryptCmd, editCmd} {
+			cmd.SetOut(nil)
+			cmd.SetErr(nil)
+		}
+	}()
+	f(identity)
+}
+
+// listDir returns the names of the files in dir
+func listDir(t *testing.T, dir string) []string {
+	entries, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+func TestEncryptCmd_File(t *testing.T) {
+	inTempProject(t, "TOKEN=secret\nURL=http://${TOKEN}@host\n", func(dir string) {
+		withIdentity(t, dir, func(identity string) {
+			var out, stderr bytes.Buffer
+			encryptCmd.SetOut(&out)
+			encryptCmd.SetErr(&stderr)
+			assert.NoError(t, encryptCmd.RunE(encryptCmd, []string{}))
+			assert.Equal(t, "Encrypted .env to .env.enc\n", out.String())
+			assert.Contains(t, stderr.String(), "created a new age identity in "+identity)
+
+			data, err := ioutil.ReadFile(filepath.Join(dir, ".env.enc"))
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(string(data), "-----BEGIN AGE ENCRYPTED FILE-----\n"))
+			assert.NotContains(t, string(data), "secret")
+			if runtime.GOOS != "windows" {
+				info, err := os.Stat(filepath.Join(dir, ".env.enc"))
+				assert.NoError(t, err)
+				assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+			}
+
+			// Only the encrypted file is kept and loaded, in memory
+			assert.NoError(t, os.Remove(filepath.Join(dir, ".env")))
+			allowFiles(t, filepath.Join(dir, ".env.enc"))
+			out.Reset()
+			envCmd.SetOut(&out)
+			assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+			assert.Contains(t, out.String(), "export TOKEN='secret'\n")
+			assert.Contains(t, out.String(), "export URL='http://secret@host'\n")
+			assert.Equal(t, []string{".env.enc"}, listDir(t, dir))
+
+			out.Reset()
+			decryptCmd.SetOut(&out)
+			assert.NoError(t, decryptCmd.RunE(decryptCmd, []string{}))
+			assert.Equal(t, "TOKEN=secret\nURL=http://${TOKEN}@host\n", out.String())
+			assert.Equal(t, []string{".env.enc"}, listDir(t, dir))
+		})
+	})
+}
+
+func TestEncryptCmd_Values(t *testing.T) {
+	inTempProject(t, "A=1\nTOKEN=secret # db\n", func(dir string) {
+		withIdentity(t, dir, func(identity string) {
+			encryptCmd.SetOut(ioutil.Discard)
+			encryptCmd.SetErr(ioutil.Discard)
+			assert.EqualError(t, encryptCmd.RunE(encryptCmd, []string{"MISSING"}), "MISSING is not set in .env")
+			assert.NoError(t, encryptCmd.RunE(encryptCmd, []string{"TOKEN"}))
+
+			data, err := ioutil.ReadFile(filepath.Join(dir, ".env"))
+			assert.NoError(t, err)
+			assert.Regexp(t, `^A=1\nTOKEN='ENC\[age:[A-Za-z0-9+/=]+\]' # db\n$`, string(data))
+
+			// Encrypting again leaves the value alone
+			assert.NoError(t, encryptCmd.RunE(encryptCmd, []string{"TOKEN"}))
+			again, err := ioutil.ReadFile(filepath.Join(dir, ".env"))
+			assert.NoError(t, err)
+			assert.Equal(t, string(data), string(again))
+
+			allowFiles(t, filepath.Join(dir, ".env"))
+			var out bytes.Buffer
+			envCmd.SetOut(&out)
+			assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+			assert.Contains(t, out.String(), "export A='1'\n")
+			assert.Contains(t, out.String(), "export TOKEN='secret'\n")
+
+			out.Reset()
+			decryptCmd.SetOut(&out)
+			assert.NoError(t, decryptCmd.RunE(decryptCmd, []string{}))
+			assert.Equal(t, "A=1\nTOKEN=secret # db\n", out.String())
+
+			// Without the identity, the value is skipped with an error
+			viper.Set("age-identity", filepath.Join(dir, "missing.txt"))
+			out.Reset()
+			stderr := captureStderr(t, func() {
+				assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+			})
+			assert.Contains(t, out.String(), "export A='1'\n")
+			assert.NotContains(t, out.String(), "TOKEN")
+			assert.Contains(t, stderr, "envtool: .env:2:1: error: cannot decrypt TOKEN: reading identity file:")
+		})
+	})
+}
+
+func TestEncryptCmd_ValuesAreLiteral(t *testing.T) {
+	inTempProject(t, "DIR=${HOME}/x\nREF=${ENVTOOL_TEST_UNSET}\nQUOTED=\"a \\\"b\\\"\"\n", func(dir string) {
+		withIdentity(t, dir, func(identity string) {
+			encryptCmd.SetOut(ioutil.Discard)
+			encryptCmd.SetErr(ioutil.Discard)
+			assert.NoError(t, encryptCmd.RunE(encryptCmd, []string{"DIR", "REF", "QUOTED"}))
+
+			// References are encrypted as written, not expanded
+			doc, err := envfile.ReadDocument(filepath.Join(dir, ".env"))
+			assert.NoError(t, err)
+			identities, err := loadKeyring().Identities()
+			assert.NoError(t, err)
+			for key, expected := range map[string]string{"DIR": "${HOME}/x", "REF": "${ENVTOOL_TEST_UNSET}", "QUOTED": `a "b"`} {
+				plaintext, err := secret.DecryptValue(doc.Lookup(key).RawValue, identities)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, plaintext, key)
+			}
+		})
+	})
+}
+
+func TestEncryptCmd_ValuesKeepExpansion(t *testing.T) {
+	inTempProject(t, "DIR=${HOME}/x\n", func(dir string) {
+		withIdentity(t, dir, func(identity string) {
+			allowFiles(t, filepath.Join(dir, ".env"))
+			var out bytes.Buffer
+			envCmd.SetOut(&out)
+			assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+			plain := out.String()
+			assert.Contains(t, plain, "export DIR='"+os.Getenv("HOME")+"/x'\n")
+
+			encryptCmd.SetOut(ioutil.Discard)
+			encryptCmd.SetErr(ioutil.Discard)
+			assert.NoError(t, encryptCmd.RunE(encryptCmd, []string{"DIR"}))
+			allowFiles(t, filepath.Join(dir, ".env"))
+
+			// The decrypted value is expanded just like the plain one
+			out.Reset()
+			assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+			assert.Contains(t, out.String(), "export DIR='"+os.Getenv("HOME")+"/x'\n")
+
+			out.Reset()
+			decryptCmd.SetOut(&out)
+			assert.NoError(t, decryptCmd.RunE(decryptCmd, []string{}))
+			assert.Equal(t, "DIR=\"${HOME}/x\"\n", out.String())
+		})
+	})
+}
+
+// editorScript writes a shell script to use as $EDITOR
+func editorScript(t *testing.T, dir, body string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("editor scripts need a POSIX shell")
+	}
+	script := filepath.Join(dir, "editor.sh")
+	assert.NoError(t, ioutil.WriteFile(script, []byte("#!/bin/sh\n"+body+"\n"), 0755))
+	origVisual, origEditor := os.Getenv("VISUAL"), os.Getenv("EDITOR")
+	os.Setenv("VISUAL", "")
+	os.Setenv("EDITOR", script)
+	t.Cleanup(func() {
+		os.Setenv("VISUAL", origVisual)
+		os.Setenv("EDITOR", origEditor)
+	})
+}
+
+func TestEditCmd_File(t *testing.T) {
+	inTempProject(t, "", func(dir string) {
+		withIdentity(t, dir, func(identity string) {
+			assert.NoError(t, os.Remove(filepath.Join(dir, ".env")))
+			editorScript(t, dir, `printf 'NEW=1\n' >> "$1"`)
+
+			var out bytes.Buffer
+			editCmd.SetOut(&out)
+			editCmd.SetErr(ioutil.Discard)
+			assert.NoError(t, editCmd.RunE(editCmd, []string{".env.enc"}))
+			assert.Equal(t, "Saved .env.enc\n", out.String())
+			info, err := os.Stat(filepath.Join(dir, ".env.enc"))
+			assert.NoError(t, err)
+			assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+			assert.NoError(t, editCmd.RunE(editCmd, []string{}))
+
+			out.Reset()
+			decryptCmd.SetOut(&out)
+			assert.NoError(t, decryptCmd.RunE(decryptCmd, []string{}))
+			assert.Equal(t, "NEW=1\nNEW=1\n", out.String())
+			assert.ElementsMatch(t, []string{".env.enc", "editor.sh"}, listDir(t, dir))
+
+			editorScript(t, dir, "true")
+			out.Reset()
+			assert.NoError(t, editCmd.RunE(editCmd, []string{}))
+			assert.Equal(t, "No changes to .env.enc\n", out.String())
+		})
+	})
+}
+
+func TestEditCmd_Values(t *testing.T) {
+	inTempProject(t, "A=1\nTOKEN=secret\nOTHER=keep\n", func(dir string) {
+		withIdentity(t, dir, func(identity string) {
+			envPath := filepath.Join(dir, ".env")
+			encryptCmd.SetOut(ioutil.Discard)
+			encryptCmd.SetErr(ioutil.Discard)
+			assert.NoError(t, encryptCmd.RunE(encryptCmd, []string{"TOKEN", "OTHER"}))
+			before, err := envfile.ReadDocument(envPath)
+			assert.NoError(t, err)
+
+			editorScript(t, dir, `sed -e 's/secret/changed/' -e 's/^A=1/A=2/' "$1" > "$1.new" && mv "$1.new" "$1"`)
+			editCmd.SetOut(ioutil.Discard)
+			assert.NoError(t, editCmd.RunE(editCmd, []string{}))
+
+			after, err := envfile.ReadDocument(envPath)
+			assert.NoError(t, err)
+			assert.Equal(t, `2`, after.Lookup("A").RawValue)
+			assert.NotEqual(t, before.Lookup("TOKEN").RawValue, after.Lookup("TOKEN").RawValue)
+			assert.Equal(t, before.Lookup("OTHER").RawValue, after.Lookup("OTHER").RawValue)
+
+			var out bytes.Buffer
+			decryptCmd.SetOut(&out)
+			assert.NoError(t, decryptCmd.RunE(decryptCmd, []string{}))
+			assert.Equal(t, "A=2\nTOKEN=changed\nOTHER=keep\n", out.String())
+		})
+	})
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/username/envtool/pkg/envfile"
+)
+
+// setCmd represents the set command
+var setCmd = &cobra.Command{
+	Use:   "set KEY=VALUE...",
+	Short: "Set variables in a .env file",
+	Long: `Set variables in the .env file given by --env-file, creating it if needed.
+
+Existing assignments are updated in place, keeping comments, ordering and
+any export prefix; new keys are appended. Values are stored literally and
+quoted as needed, so they are never expanded when the file is loaded.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envFilePath, err := targetEnvFile()
+		if err != nil {
+			return err
+		}
+
+		doc, err := envfile.ReadDocument(envFilePath)
+		if os.IsNotExist(err) {
+			doc = envfile.ParseDocument(envFilePath, nil)
+		} else if err != nil {
+			return err
+		}
+
+		for _, arg := range args {
+			parts := strings.SplitN(arg, "=", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("expected KEY=VALUE, got %q", arg)
+			}
+			if !envfile.IsValidKey(parts[0]) {
+				return fmt.Errorf("invalid key name %q", parts[0])
+			}
+			doc.Set(parts[0], parts[1])
+		}
+
+		return doc.WriteFile(envFilePath)
+	},
+}
+
+// unsetCmd represents the unset command
+var unsetCmd = &cobra.Command{
+	Use:   "unset KEY...",
+	Short: "Remove variables from a .env file",
+	Long: `Remove every assignment to the given keys from the .env file given by
+--env-file, keeping the rest of the file unchanged.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envFilePath, err := targetEnvFile()
+		if err != nil {
+			return err
+		}
+
+		doc, err := envfile.ReadDocument(envFilePath)
+		if err != nil {
+			return err
+		}
+
+		changed := false
+		for _, key := range args {
+			if !envfile.IsValidKey(key) {
+				return fmt.Errorf("invalid key name %q", key)
+			}
+			if doc.Unset(key) {
+				changed = true
+			}
+		}
+
+		if !changed {
+			return nil
+		}
+		return doc.WriteFile(envFilePath)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(setCmd)
+	rootCmd.AddCommand(unsetCmd)
+}
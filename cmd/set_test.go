@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetAndUnsetCmd(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-set-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	envPath := filepath.Join(tempDir, ".env")
+	initial := "# database\nDB_HOST=localhost # dev only\nDB_PORT=5432\n"
+	assert.NoError(t, ioutil.WriteFile(envPath, []byte(initial), 0600))
+
+	origEnvFile := viper.GetString("env-file")
+	defer viper.Set("env-file", origEnvFile)
+	viper.Set("env-file", envPath)
+
+	err = setCmd.RunE(setCmd, []string{"DB_HOST=db.internal", "DB_PASS=p@ss word$1"})
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "# database\nDB_HOST=db.internal # dev only\nDB_PORT=5432\nDB_PASS='p@ss word$1'\n", string(content))
+
+	info, err := os.Stat(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	err = unsetCmd.RunE(unsetCmd, []string{"DB_PORT", "NOT_THERE"})
+	assert.NoError(t, err)
+
+	content, err = ioutil.ReadFile(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "# database\nDB_HOST=db.internal # dev only\nDB_PASS='p@ss word$1'\n", string(content))
+}
+
+func TestSetCmd_CreatesFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-set-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	envPath := filepath.Join(tempDir, ".env")
+	origEnvFile := viper.GetString("env-file")
+	defer viper.Set("env-file", origEnvFile)
+	viper.Set("env-file", envPath)
+
+	assert.NoError(t, setCmd.RunE(setCmd, []string{"FOO=bar"}))
+	content, err := ioutil.ReadFile(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "FOO=bar\n", string(content))
+}
+
+func TestSetCmd_InvalidArgs(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-set-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	envPath := filepath.Join(tempDir, ".env")
+	origEnvFile := viper.GetString("env-file")
+	defer viper.Set("env-file", origEnvFile)
+	viper.Set("env-file", envPath)
+
+	assert.EqualError(t, setCmd.RunE(setCmd, []string{"NOVALUE"}), `expected KEY=VALUE, got "NOVALUE"`)
+	assert.EqualError(t, setCmd.RunE(setCmd, []string{"FOO;rm=x"}), `invalid key name "FOO;rm"`)
+	assert.Error(t, unsetCmd.RunE(unsetCmd, []string{"FOO"}), "unset on a missing file fails")
+
+	_, err = os.Stat(envPath)
+	assert.True(t, os.IsNotExist(err), "failed set must not create the file")
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/username/envtool/pkg/trust"
+)
+
+// allowCmd represents the allow command
+var allowCmd = &cobra.Command{
+	Use:   "allow [file...]",
+	Short: "Trust .env files so that envtool env loads them",
+	Long: `Trust the current content of .env files. envtool env only loads files
+that were allowed, and only as long as their content does not change.
+
+Without arguments, the files envtool env would load are allowed.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadTrustStore()
+		if err != nil {
+			return err
+		}
+		paths, err := trustCommandPaths(args)
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("no .env files to allow")
+		}
+
+		for _, path := range paths {
+			entry, err := store.Allow(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Allowed %s\n", entry.Path)
+		}
+		return store.Save()
+	},
+}
+
+// denyCmd represents the deny command
+var denyCmd = &cobra.Command{
+	Use:   "deny [file...]",
+	Short: "Revoke trust in .env files",
+	Long: `Revoke trust in .env files, so that envtool env no longer loads them.
+
+Without arguments, the files envtool env would load are denied.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadTrustStore()
+		if err != nil {
+			return err
+		}
+		paths, err := trustCommandPaths(args)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			allowed, err := store.Deny(path)
+			if err != nil {
+				return err
+			}
+			if allowed {
+				fmt.Fprintf(cmd.OutOrStdout(), "Denied %s\n", path)
+			} else {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s was not allowed\n", path)
+			}
+		}
+		return store.Save()
+	},
+}
+
+// trustCmd groups the trust database commands
+var trustCmd = &cobra.Command{
+	Use:   "trust",
+	Short: "Manage the trust database",
+}
+
+// trustListCmd represents the trust list command
+var trustListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List allowed .env files",
+	Long: `List the allowed .env files with their status: trusted, changed since
+they were allowed, or missing.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadTrustStore()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		for _, entry := range store.Entries() {
+			status := "missing"
+			if s, err := store.Check(entry.Path); err == nil {
+				status = s.String()
+			} else if !os.IsNotExist(err) {
+				return err
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", status, entry.Path, entry.Allowed.Format("2006-01-02 15:04:05"))
+		}
+		return w.Flush()
+	},
+}
+
+// trustStorePath returns the trust database file: the trust-db setting,
+// or $ENVTOOL_TRUST_DB, or the default location
+func trustStorePath() (string, error) {
+	if path := viper.GetString("trust-db"); path != "" {
+		return path, nil
+	}
+	return trust.DefaultPath()
+}
+
+func loadTrustStore() (*trust.Store, error) {
+	path, err := trustStorePath()
+	if err != nil {
+		return nil, err
+	}
+	return trust.Load(path)
+}
+
+// trustCommandPaths returns the files given to allow or deny, or the
+// existing files envtool env would load, including the schema if its
+// defaults are used
+func trustCommandPaths(args []string) ([]string, error) {
+	if len(args) > 0 {
+		return args, nil
+	}
+	configured, err := envFilePaths()
+	if err != nil {
+		return nil, err
+	}
+	if viper.GetBool("schema.defaults") {
+		configured = append(configured, schemaPath())
+	}
+	var paths []string
+	for _, path := range configured {
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// trustedFiles returns the paths that are trusted or do not exist, and
+// prints a one-line notice to stderr for each file left out
+func trustedFiles(store *trust.Store, paths []string, stderr io.Writer) []string {
+	var trusted []string
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			// Missing files are skipped when parsing
+			trusted = append(trusted, path)
+			continue
+		}
+		status, err := store.Check(path)
+		switch {
+		case err != nil:
+			fmt.Fprintf(stderr, "envtool: not loading %s: %v\n", path, err)
+		case status == trust.Trusted:
+			trusted = append(trusted, path)
+		case status == trust.Changed:
+			fmt.Fprintf(stderr, "envtool: %s changed since it was allowed; run \"envtool allow %s\" to load it\n", path, path)
+		default:
+			fmt.Fprintf(stderr, "envtool: %s is not trusted; run \"envtool allow %s\" to load it\n", path, path)
+		}
+	}
+	return trusted
+}
+
+func init() {
+	rootCmd.AddCommand(allowCmd)
+	rootCmd.AddCommand(denyCmd)
+	rootCmd.AddCommand(trustCmd)
+	trustCmd.AddCommand(trustListCmd)
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain keeps the tests away from the user's trust database, age
+// identity and command cache
+func TestMain(m *testing.M) {
+	tempDir, err := ioutil.TempDir("", "envtool-trust")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("ENVTOOL_TRUST_DB", filepath.Join(tempDir, "trust.json"))
+	os.Setenv("ENVTOOL_AGE_IDENTITY", filepath.Join(tempDir, "identity.txt"))
+	os.Setenv("ENVTOOL_COMMAND_CACHE", filepath.Join(tempDir, "commands.json"))
+	code := m.Run()
+	os.RemoveAll(tempDir)
+	os.Exit(code)
+}
+
+// allowFiles trusts files for the rest of the test run
+func allowFiles(tb testing.TB, paths ...string) {
+	store, err := loadTrustStore()
+	if err != nil {
+		tb.Fatalf("Failed to load trust database: %v", err)
+	}
+	for _, path := range paths {
+		if _, err := store.Allow(path); err != nil {
+			tb.Fatalf("Failed to allow %s: %v", path, err)
+		}
+	}
+	if err := store.Save(); err != nil {
+		tb.Fatalf("Failed to save trust database: %v", err)
+	}
+}
+
+func TestTrustCommands(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-trust-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	tempDir, err = filepath.EvalSymlinks(tempDir)
+	assert.NoError(t, err)
+
+	envPath := filepath.Join(tempDir, ".env")
+	assert.NoError(t, ioutil.WriteFile(envPath, []byte("FOO=bar\n"), 0644))
+
+	// Start from an empty trust database
+	viper.Set("trust-db", filepath.Join(tempDir, "trust.json"))
+	defer viper.Set("trust-db", os.Getenv("ENVTOOL_TRUST_DB"))
+
+	origDir, err := os.Getwd()
+	assert.NoError(t, err)
+	defer os.Chdir(origDir)
+	assert.NoError(t, os.Chdir(tempDir))
+
+	var out bytes.Buffer
+	for _, c := range []*cobra.Command{envCmd, allowCmd, denyCmd, trustListCmd} {
+		c.SetOut(&out)
+	}
+	defer func() {
+		for _, c := range []*cobra.Command{envCmd, allowCmd, denyCmd, trustListCmd} {
+			c.SetOut(nil)
+		}
+	}()
+
+	// Untrusted files are not loaded, and the notice is one line
+	stderr := captureStderr(t, func() {
+		assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+	})
+	assert.Equal(t, "envtool: .env is not trusted; run \"envtool allow .env\" to load it\n", stderr)
+	assert.NotContains(t, out.String(), "FOO")
+
+	// allow without arguments trusts the configured files
+	out.Reset()
+	assert.NoError(t, allowCmd.RunE(allowCmd, []string{}))
+	assert.Equal(t, "Allowed "+envPath+"\n", out.String())
+
+	out.Reset()
+	envForce = true
+	defer func() { envForce = false }()
+	stderr = captureStderr(t, func() {
+		assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+	})
+	assert.Equal(t, "", stderr)
+	assert.Contains(t, out.String(), "export FOO='bar'\n")
+
+	out.Reset()
+	assert.NoError(t, trustListCmd.RunE(trustListCmd, []string{}))
+	assert.True(t, strings.HasPrefix(out.String(), "trusted  "+envPath+"  "), out.String())
+
+	// Changing the file revokes trust
+	assert.NoError(t, ioutil.WriteFile(envPath, []byte("FOO=evil\n"), 0644))
+	out.Reset()
+	stderr = captureStderr(t, func() {
+		assert.NoError(t, envCmd.RunE(envCmd, []string{}))
+	})
+	assert.Equal(t, "envtool: .env changed since it was allowed; run \"envtool allow .env\" to load it\n", stderr)
+	assert.NotContains(t, out.String(), "FOO=evil")
+
+	out.Reset()
+	assert.NoError(t, trustListCmd.RunE(trustListCmd, []string{}))
+	assert.True(t, strings.HasPrefix(out.String(), "changed  "+envPath+"  "), out.String())
+
+	out.Reset()
+	assert.NoError(t, denyCmd.RunE(denyCmd, []string{envPath}))
+	assert.Equal(t, "Denied "+envPath+"\n", out.String())
+	out.Reset()
+	assert.NoError(t, trustListCmd.RunE(trustListCmd, []string{}))
+	assert.Equal(t, "", out.String())
+}
+
+// captureStderr returns what f writes to os.Stderr
+func captureStderr(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	orig := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = orig }()
+
+	f()
+	w.Close()
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	return string(data)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/username/envtool/pkg/shell"
+)
+
+// uninitCmd represents the uninit command
+var uninitCmd = &cobra.Command{
+	Use:   "uninit",
+	Short: "Remove envtool hooks from shell configuration",
+	Long: `Remove the hooks installed by envtool init from shell rc files.
+
+The same flags as init select the files: system-wide by default, or
+user-specific with --user, for bash and zsh unless shells are selected
+with --bash, --zsh, --fish, --pwsh or --nu. A single rc file can also be
+given as an argument when one shell is selected.
+
+Hooks between the "# BEGIN envtool" and "# END envtool" markers are
+removed, as are the unmarked hooks appended by envtool versions before the
+markers were introduced. Before a file is changed, its previous content
+is saved next to it as FILE.<timestamp>.envtool.bak.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fileManager := &shell.DefaultFileManager{}
+
+		targets, _, err := selectHookTargets(args)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Shell configurations:\n")
+		for _, target := range targets {
+			result, err := removeHook(fileManager, target.Name, target.Path)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("- %s: %s: %s\n", target.Name, target.Path, result)
+		}
+		return nil
+	},
+}
+
+// removeHook removes the envtool hook blocks from a shell configuration
+// file, keeping a backup of the previous content. It returns a short
+// description of what it did.
+func removeHook(fileManager shell.FileManager, shellName, path string) (string, error) {
+	exists, err := fileManager.FileExists(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to check %s configuration: %w", shellName, err)
+	}
+	if !exists {
+		return "not found", nil
+	}
+
+	content, err := fileManager.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s configuration: %w", shellName, err)
+	}
+	updated, removed, err := shell.RemoveHookBlocks(content)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	if removed == 0 {
+		return "no envtool hook", nil
+	}
+
+	backupPath, err := fileManager.BackupFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to back up %s configuration: %w", shellName, err)
+	}
+	if err := fileManager.WriteFile(path, updated); err != nil {
+		return "", fmt.Errorf("failed to update %s configuration: %w", shellName, err)
+	}
+	return fmt.Sprintf("removed %d hook block(s), backup in %s", removed, backupPath), nil
+}
+
+func init() {
+	rootCmd.AddCommand(uninitCmd)
+
+	// Same configuration path flags as init
+	uninitCmd.Flags().StringVar(&bashrcPath, "bashrc", defaultBashrcPath, "Path to bash configuration file")
+	uninitCmd.Flags().StringVar(&zshrcPath, "zshrc", defaultZshrcPath, "Path to zsh configuration file")
+	uninitCmd.Flags().StringVar(&fishConfPath, "fish-conf", defaultFishConfPath, "Path to fish conf.d hook file")
+	uninitCmd.Flags().StringVar(&pwshProfile, "pwsh-profile", defaultPwshProfilePath, "Path to PowerShell profile")
+	uninitCmd.Flags().StringVar(&nuConfigPath, "nu-config", "", "Path to nushell config.nu (default with --user: ~/.config/nushell/config.nu)")
+	uninitCmd.Flags().BoolVar(&userOnly, "user", false, "Modify user-specific configuration files instead of system-wide")
+	uninitCmd.Flags().BoolVar(&bashOnly, "bash", false, "Only update bash configuration (default: both shells)")
+	uninitCmd.Flags().BoolVar(&zshOnly, "zsh", false, "Only update zsh configuration (default: both shells)")
+	uninitCmd.Flags().BoolVar(&fishOnly, "fish", false, "Update fish configuration (not included by default)")
+	uninitCmd.Flags().BoolVar(&pwshOnly, "pwsh", false, "Update PowerShell profile (not included by default)")
+	uninitCmd.Flags().BoolVar(&nuOnly, "nu", false, "Update nushell configuration (not included by default)")
+}
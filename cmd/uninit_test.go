@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/username/envtool/pkg/shell"
+)
+
+func TestUninitCmd(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-test-uninit")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	bashrc := filepath.Join(tempDir, "bashrc")
+	zshrc := filepath.Join(tempDir, "zshrc")
+	original := "alias ll='ls -l'\n"
+	assert.NoError(t, ioutil.WriteFile(bashrc, []byte(original), 0644))
+
+	// Save original flags
+	origBashrc := bashrcPath
+	origZshrc := zshrcPath
+	origUserOnly := userOnly
+	origBashOnly := bashOnly
+	origZshOnly := zshOnly
+	defer func() {
+		bashrcPath = origBashrc
+		zshrcPath = origZshrc
+		userOnly = origUserOnly
+		bashOnly = origBashOnly
+		zshOnly = origZshOnly
+	}()
+
+	bashrcPath = bashrc
+	zshrcPath = zshrc
+	userOnly = false
+	bashOnly = false
+	zshOnly = false
+
+	// Install, then remove the hooks again
+	assert.NoError(t, initCmd.RunE(initCmd, []string{}))
+	content, err := ioutil.ReadFile(bashrc)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "# BEGIN envtool v2 shell=bash\n")
+	assert.Contains(t, string(content), "# END envtool\n")
+	installed := string(content)
+
+	assert.NoError(t, uninitCmd.RunE(uninitCmd, []string{}))
+
+	content, err = ioutil.ReadFile(bashrc)
+	assert.NoError(t, err)
+	assert.Equal(t, original, string(content))
+	content, err = ioutil.ReadFile(zshrc)
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(content))
+
+	// The previous content is kept as a backup
+	backups, err := filepath.Glob(bashrc + ".*.envtool.bak")
+	assert.NoError(t, err)
+	if assert.Len(t, backups, 1) {
+		backup, err := ioutil.ReadFile(backups[0])
+		assert.NoError(t, err)
+		assert.Equal(t, installed, string(backup))
+	}
+
+	// Running again changes nothing
+	assert.NoError(t, uninitCmd.RunE(uninitCmd, []string{}))
+	backups, err = filepath.Glob(bashrc + ".*.envtool.bak")
+	assert.NoError(t, err)
+	assert.Len(t, backups, 1)
+}
+
+func TestRemoveHook_FakeFileManager(t *testing.T) {
+	fm := newFakeFileManager()
+	fm.files["/etc/bash.bashrc"] = "before\n" + shell.HookBlock("v1 shell=bash", "hook") + "after\n"
+
+	result, err := removeHook(fm, "Bash", "/etc/bash.bashrc")
+	assert.NoError(t, err)
+	assert.Equal(t, "removed 1 hook block(s), backup in /etc/bash.bashrc.bak1", result)
+	assert.Equal(t, "before\nafter\n", fm.files["/etc/bash.bashrc"])
+	assert.Contains(t, fm.files["/etc/bash.bashrc.bak1"], "# BEGIN envtool")
+
+	// A failed write is reported
+	fm.files["/etc/bash.bashrc"] = shell.HookBlock("", "hook")
+	fm.writeErr = errors.New("disk full")
+	_, err = removeHook(fm, "Bash", "/etc/bash.bashrc")
+	assert.EqualError(t, err, "failed to back up Bash configuration: disk full")
+}
+
+func TestRemoveHook_Legacy(t *testing.T) {
+	// A bashrc set up by envtool init before hooks had markers
+	legacy := `
+_envtool_hook() {
+  local previous_exit_status=$?;
+  trap -- '' SIGINT;
+  eval "$(envtool env bash)";
+  trap - SIGINT;
+  return $previous_exit_status;
+};
+if ! [[ "${PROMPT_COMMAND:-}" =~ _envtool_hook ]]; then
+  PROMPT_COMMAND="_envtool_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`
+	fm := newFakeFileManager()
+	fm.files["/etc/bash.bashrc"] = "alias ll='ls -l'\n" + legacy
+
+	result, err := removeHook(fm, "Bash", "/etc/bash.bashrc")
+	assert.NoError(t, err)
+	assert.Equal(t, "removed 1 hook block(s), backup in /etc/bash.bashrc.bak1", result)
+	assert.Equal(t, "alias ll='ls -l'\n", fm.files["/etc/bash.bashrc"])
+}
+
+func TestRemoveHook_Missing(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-test-uninit")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "missing")
+	result, err := removeHook(&shell.DefaultFileManager{}, "Bash", path)
+	assert.NoError(t, err)
+	assert.Equal(t, "not found", result)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,75 @@
+// Package atomicfile replaces files atomically. Content is written to a
+// temporary file in the target's directory, synced and renamed over the
+// target, so that readers and crashes never see a partial file.
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile atomically replaces the file at path, or the file a symlink at
+// path points to, with data. An existing file keeps its mode and, where
+// permitted, its owner and group; a new file is created with mode perm.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return write(path, data, info, perm)
+}
+
+// WriteFileLike atomically writes data to path, giving the new file the
+// mode and, where permitted, the owner and group described by info. It is
+// meant for copies of another file, such as backups.
+func WriteFileLike(path string, data []byte, info os.FileInfo) error {
+	return write(path, data, info, info.Mode().Perm())
+}
+
+// write writes data to a temporary file next to path and renames it over
+// path. The new file takes its mode and owner from info, or gets mode perm
+// if info is nil.
+func write(path string, data []byte, info os.FileInfo, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// Removing fails harmlessly once the file has been renamed
+	defer os.Remove(tmp.Name())
+
+	mode := perm
+	if info != nil {
+		mode = info.Mode().Perm()
+		if err := chownLike(tmp, info); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
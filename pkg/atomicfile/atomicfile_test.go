@@ -0,0 +1,65 @@
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "atomicfile-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// A new file gets perm
+	path := filepath.Join(tempDir, "db.json")
+	assert.NoError(t, WriteFile(path, []byte("one"), 0600))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// An existing file keeps its mode, and a symlink its target
+	assert.NoError(t, os.Chmod(path, 0640))
+	link := filepath.Join(tempDir, "link")
+	assert.NoError(t, os.Symlink(path, link))
+	assert.NoError(t, WriteFile(link, []byte("two"), 0600))
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "two", string(content))
+	info, err = os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	dest, err := os.Readlink(link)
+	assert.NoError(t, err)
+	assert.Equal(t, path, dest)
+
+	// No temporary files are left behind
+	entries, err := ioutil.ReadDir(tempDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestWriteFileLike(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "atomicfile-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	original := filepath.Join(tempDir, "bashrc")
+	assert.NoError(t, ioutil.WriteFile(original, []byte("content"), 0600))
+	info, err := os.Stat(original)
+	assert.NoError(t, err)
+
+	copyPath := original + ".bak"
+	assert.NoError(t, WriteFileLike(copyPath, []byte("content"), info))
+	copied, err := os.Stat(copyPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), copied.Mode().Perm())
+}
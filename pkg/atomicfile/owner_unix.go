@@ -0,0 +1,54 @@
+//go:build !windows
+// +build !windows
+
+package atomicfile
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// chownLike gives f the owner and group of the file described by info,
+// changing only the IDs that differ. Ownership is kept on a best-effort
+// basis: a user who owns a file without being in its group may not hand
+// that group on, and the new file then keeps the user's group rather than
+// failing the write.
+func chownLike(f *os.File, info os.FileInfo) error {
+	want, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	current, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	have, ok := current.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	uid, gid := -1, -1
+	if have.Uid != want.Uid {
+		uid = int(want.Uid)
+	}
+	if have.Gid != want.Gid {
+		gid = int(want.Gid)
+	}
+	if uid == -1 && gid == -1 {
+		return nil
+	}
+	if err := f.Chown(uid, gid); err != nil && !errors.Is(err, syscall.EPERM) {
+		return err
+	}
+	return nil
+}
+
+// syncDir flushes a directory so that a rename in it is durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,52 @@
+//go:build !windows
+// +build !windows
+
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// statInfo is a FileInfo carrying only ownership
+type statInfo struct {
+	os.FileInfo
+	stat *syscall.Stat_t
+}
+
+func (i statInfo) Sys() interface{} { return i.stat }
+
+func TestChownLike_ForeignGroup(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root may give files any group")
+	}
+	groups, err := os.Getgroups()
+	assert.NoError(t, err)
+	member := map[int]bool{os.Getgid(): true}
+	for _, g := range groups {
+		member[g] = true
+	}
+	foreign := 0
+	for member[foreign] {
+		foreign++
+	}
+
+	tempDir, err := ioutil.TempDir("", "atomicfile-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// A file the user owns, in a group the user is not a member of,
+	// can still be replaced; the new file keeps the user's group
+	f, err := os.Create(filepath.Join(tempDir, "bashrc"))
+	assert.NoError(t, err)
+	defer f.Close()
+	info := statInfo{stat: &syscall.Stat_t{Uid: uint32(os.Geteuid()), Gid: uint32(foreign)}}
+	assert.NoError(t, chownLike(f, info))
+}
@@ -0,0 +1,13 @@
+package atomicfile
+
+import "os"
+
+// chownLike is a no-op; Windows files have no Unix owner
+func chownLike(f *os.File, info os.FileInfo) error {
+	return nil
+}
+
+// syncDir is a no-op; directories cannot be synced on Windows
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,155 @@
+// Package cache keeps the output of commands run while loading .env files,
+// so that the shell prompt hook does not run them again at every prompt.
+// Entries are looked up by a key that covers everything the output
+// depends on, such as the command, its directory and the files it watches.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/username/envtool/pkg/atomicfile"
+)
+
+// MaxAge is how long an entry is kept at most, however it is used
+const MaxAge = 24 * time.Hour
+
+// Entry is a cached output
+type Entry struct {
+	// Key identifies the command, see Key
+	Key string `json:"key"`
+	// Value is the command's output
+	Value string `json:"value"`
+	// Stored is when the command was run
+	Stored time.Time `json:"stored"`
+	// Transient entries live in memory only and are never saved
+	Transient bool `json:"-"`
+}
+
+// Store is a cache kept in a JSON file
+type Store struct {
+	// Path is the cache file
+	Path    string
+	entries map[string]Entry
+	changed bool
+}
+
+// DefaultPath returns $XDG_CACHE_HOME/envtool/commands.json, falling back
+// to ~/.cache/envtool/commands.json
+func DefaultPath() (string, error) {
+	cacheDir := os.Getenv("XDG_CACHE_HOME")
+	if cacheDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil || home == "" {
+			return "", fmt.Errorf("cannot locate the command cache: HOME is not set")
+		}
+		cacheDir = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(cacheDir, "envtool", "commands.json"), nil
+}
+
+// Key returns a cache key made of parts
+func Key(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(h, "%q\n", part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Load reads the cache at path. A missing or malformed cache is empty.
+func Load(path string) (*Store, error) {
+	s := &Store{Path: path, entries: map[string]Entry{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		// The cache can always be rebuilt
+		s.changed = true
+		return s, nil
+	}
+	for _, e := range entries {
+		s.entries[e.Key] = e
+	}
+	return s, nil
+}
+
+// Get returns the value stored under key less than maxAge ago
+func (s *Store) Get(key string, maxAge time.Duration) (string, bool) {
+	e, ok := s.entries[key]
+	if !ok || time.Since(e.Stored) >= maxAge || time.Since(e.Stored) >= MaxAge {
+		return "", false
+	}
+	return e.Value, true
+}
+
+// Put stores value under key
+func (s *Store) Put(key, value string) {
+	s.entries[key] = Entry{Key: key, Value: value, Stored: time.Now().UTC()}
+	s.changed = true
+}
+
+// PutTransient stores value under key for as long as the store is in
+// memory. The entry is never written to disk, which suits outputs that
+// are secrets.
+func (s *Store) PutTransient(key, value string) {
+	s.entries[key] = Entry{Key: key, Value: value, Stored: time.Now().UTC(), Transient: true}
+}
+
+// Save writes the cache atomically, readable only by the user, leaving
+// out transient entries and entries older than MaxAge. Nothing is written if nothing changed.
+func (s *Store) Save() error {
+	for key, e := range s.entries {
+		if time.Since(e.Stored) >= MaxAge {
+			delete(s.entries, key)
+			s.changed = true
+		}
+	}
+	if !s.changed {
+		return nil
+	}
+
+	saved := []Entry{}
+	for _, e := range s.Entries() {
+		if !e.Transient {
+			saved = append(saved, e)
+		}
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(s.Path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(s.Path, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	s.changed = false
+	return nil
+}
+
+// Entries returns the cached outputs sorted by key
+func (s *Store) Entries() []Entry {
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "cache-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "cache", "commands.json")
+
+	// A missing cache is empty, and is not written until it changes
+	store, err := Load(path)
+	assert.NoError(t, err)
+	_, ok := store.Get(Key("a"), time.Hour)
+	assert.False(t, ok)
+	assert.NoError(t, store.Save())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	key := Key("dir", "git rev-parse HEAD")
+	assert.NotEqual(t, Key("dir", "git rev-parse HEAD", ""), key)
+	assert.NotEqual(t, Key("dirgit", " rev-parse HEAD"), key)
+	store.Put(key, "abc123")
+	assert.NoError(t, store.Save())
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Entries survive a reload, for as long as the caller allows
+	store, err = Load(path)
+	assert.NoError(t, err)
+	value, ok := store.Get(key, time.Hour)
+	assert.True(t, ok)
+	assert.Equal(t, "abc123", value)
+	_, ok = store.Get(key, 0)
+	assert.False(t, ok)
+
+	// Transient entries are only kept in memory
+	secretKey := Key("dir", "pass show db")
+	store.PutTransient(secretKey, "hunter2")
+	value, ok = store.Get(secretKey, time.Hour)
+	assert.True(t, ok)
+	assert.Equal(t, "hunter2", value)
+	store.Put(key, "abc123")
+	assert.NoError(t, store.Save())
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "hunter2")
+	store, err = Load(path)
+	assert.NoError(t, err)
+	_, ok = store.Get(secretKey, time.Hour)
+	assert.False(t, ok)
+
+	// Old entries are dropped when saving
+	store.entries[key] = Entry{Key: key, Value: "abc123", Stored: time.Now().Add(-MaxAge)}
+	_, ok = store.Get(key, 2*MaxAge)
+	assert.False(t, ok)
+	assert.NoError(t, store.Save())
+	store, err = Load(path)
+	assert.NoError(t, err)
+	assert.Empty(t, store.Entries())
+
+	// A malformed cache is rebuilt
+	assert.NoError(t, ioutil.WriteFile(path, []byte("{"), 0600))
+	store, err = Load(path)
+	assert.NoError(t, err)
+	assert.Empty(t, store.Entries())
+	assert.NoError(t, store.Save())
+	data, err = ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "[]\n", string(data))
+}
@@ -0,0 +1,60 @@
+package emitter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Change is a single modification of the environment
+type Change struct {
+	Key   string
+	Value string
+	// Unset removes Key from the environment instead of setting it
+	Unset bool
+}
+
+// Emitter renders environment changes as commands for one shell. Each
+// emitter owns the quoting rules of its shell and always quotes values, so
+// that no value can turn into code when the output is evaluated. Changes
+// whose key is not a valid variable name for the shell are left out.
+type Emitter interface {
+	Emit(changes []Change) string
+	// ValidKey reports whether key can be used as a variable name
+	ValidKey(key string) bool
+	// Quote renders value as a single literal word for the shell
+	Quote(value string) string
+}
+
+// ForShell returns the emitter for a shell name as passed to envtool env
+func ForShell(name string) (Emitter, error) {
+	switch strings.ToLower(name) {
+	case "", "bash", "zsh", "sh", "dash", "ksh":
+		return &Posix{}, nil
+	case "fish":
+		return &Fish{}, nil
+	case "pwsh", "powershell":
+		return &PowerShell{}, nil
+	case "nu", "nushell":
+		return &Nushell{}, nil
+	}
+	return nil, fmt.Errorf("unsupported shell %q", name)
+}
+
+// isIdentifier reports whether key is a letter or underscore followed by
+// letters, digits or underscores. Every supported shell accepts such
+// names without quoting.
+func isIdentifier(key string) bool {
+	if key == "" || key[0] >= '0' && key[0] <= '9' {
+		return false
+	}
+	return strings.Trim(key, wordChars) == ""
+}
+
+// isSafe reports whether value is non-empty and made only of characters
+// from safe, so that it needs no quoting
+func isSafe(value, safe string) bool {
+	return value != "" && strings.Trim(value, safe) == ""
+}
+
+// wordChars are letters, digits and underscore
+const wordChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_"
@@ -0,0 +1,147 @@
+package emitter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testChanges = []Change{
+	{Key: "OLD", Unset: true},
+	{Key: "PLAIN", Value: "bar"},
+	{Key: "EMPTY", Value: ""},
+	{Key: "QUOTES", Value: `it's "quoted"`},
+	{Key: "SPECIAL", Value: "$HOME \\ `x`\nnext"},
+	{Key: "GONE", Unset: true},
+}
+
+func TestForShell(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected Emitter
+	}{
+		{name: "bash", expected: &Posix{}},
+		{name: "zsh", expected: &Posix{}},
+		{name: "", expected: &Posix{}},
+		{name: "fish", expected: &Fish{}},
+		{name: "pwsh", expected: &PowerShell{}},
+		{name: "PowerShell", expected: &PowerShell{}},
+		{name: "nu", expected: &Nushell{}},
+		{name: "nushell", expected: &Nushell{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := ForShell(tc.name)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, em)
+		})
+	}
+
+	_, err := ForShell("cmd.exe")
+	assert.EqualError(t, err, `unsupported shell "cmd.exe"`)
+}
+
+func TestEmit(t *testing.T) {
+	testCases := []struct {
+		name     string
+		emitter  Emitter
+		expected string
+	}{
+		{
+			name:    "Posix",
+			emitter: &Posix{},
+			expected: "unset OLD\n" +
+				"export PLAIN='bar'\n" +
+				"export EMPTY=''\n" +
+				`export QUOTES='it'"'"'s "quoted"'` + "\n" +
+				"export SPECIAL='$HOME \\ `x`\nnext'\n" +
+				"unset GONE",
+		},
+		{
+			name:    "Fish",
+			emitter: &Fish{},
+			expected: "set -e OLD\n" +
+				"set -gx PLAIN bar\n" +
+				"set -gx EMPTY ''\n" +
+				`set -gx QUOTES 'it\'s "quoted"'` + "\n" +
+				"set -gx SPECIAL '$HOME \\\\ `x`\nnext'\n" +
+				"set -e GONE",
+		},
+		{
+			name:    "PowerShell",
+			emitter: &PowerShell{},
+			expected: "Remove-Item -Path Env:OLD -ErrorAction SilentlyContinue\n" +
+				"$env:PLAIN = 'bar'\n" +
+				"$env:EMPTY = ''\n" +
+				`$env:QUOTES = 'it''s "quoted"'` + "\n" +
+				"$env:SPECIAL = '$HOME \\ `x`\nnext'\n" +
+				"Remove-Item -Path Env:GONE -ErrorAction SilentlyContinue",
+		},
+		{
+			name:     "Nushell",
+			emitter:  &Nushell{},
+			expected: `{"unset":["OLD","GONE"],"set":{"PLAIN":"bar","EMPTY":"","QUOTES":"it's \"quoted\"","SPECIAL":"$HOME \\ ` + "`x`" + `\nnext"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.emitter.Emit(testChanges))
+		})
+	}
+}
+
+func TestEmit_Empty(t *testing.T) {
+	for _, em := range []Emitter{&Posix{}, &Fish{}, &PowerShell{}, &Nushell{}} {
+		assert.Equal(t, "", em.Emit(nil))
+	}
+}
+
+func TestPowerShellQuote_TypographicQuotes(t *testing.T) {
+	assert.Equal(t, "'a’’b'", powerShellQuote("a’b"))
+}
+
+func TestNushellQuote_ControlCharacters(t *testing.T) {
+	assert.Equal(t, `"a\u{1b}b\t"`, nushellQuote("a\x1bb\t"))
+}
+
+func TestNushellEmit_JSON(t *testing.T) {
+	changes := []Change{
+		{Key: "MULTI", Value: "line one\nline two\r\n\x1b"},
+		{Key: "OLD", Unset: true},
+	}
+	var decoded struct {
+		Unset []string
+		Set   map[string]string
+	}
+	output := (&Nushell{}).Emit(changes)
+	assert.NotContains(t, output, "\n")
+	assert.NoError(t, json.Unmarshal([]byte(output), &decoded))
+	assert.Equal(t, []string{"OLD"}, decoded.Unset)
+	assert.Equal(t, map[string]string{"MULTI": "line one\nline two\r\n\x1b"}, decoded.Set)
+}
+
+func TestValidKey(t *testing.T) {
+	for _, em := range []Emitter{&Posix{}, &Fish{}, &PowerShell{}, &Nushell{}} {
+		for _, key := range []string{"FOO", "_foo", "A1_B2"} {
+			assert.True(t, em.ValidKey(key), "%T %q", em, key)
+		}
+		for _, key := range []string{"", "1FOO", "FOO;rm", "FOO BAR", "FOO-BAR", "$(x)", "FÖÖ"} {
+			assert.False(t, em.ValidKey(key), "%T %q", em, key)
+		}
+	}
+}
+
+func TestEmit_SkipsInvalidKeys(t *testing.T) {
+	changes := []Change{
+		{Key: "FOO;touch pwned", Value: "x"},
+		{Key: "$(touch pwned)", Unset: true},
+		{Key: "OK", Value: "x"},
+	}
+	assert.Equal(t, "export OK='x'", (&Posix{}).Emit(changes))
+	assert.Equal(t, "set -gx OK x", (&Fish{}).Emit(changes))
+	assert.Equal(t, "$env:OK = 'x'", (&PowerShell{}).Emit(changes))
+	assert.Equal(t, `{"unset":[],"set":{"OK":"x"}}`, (&Nushell{}).Emit(changes))
+}
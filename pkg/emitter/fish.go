@@ -0,0 +1,47 @@
+package emitter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fish emits set -gx/set -e commands for the fish shell
+type Fish struct{}
+
+// Emit renders changes as one set command per line
+func (e *Fish) Emit(changes []Change) string {
+	commands := make([]string, 0, len(changes))
+	for _, c := range changes {
+		if !e.ValidKey(c.Key) {
+			continue
+		}
+		if c.Unset {
+			commands = append(commands, fmt.Sprintf("set -e %s", c.Key))
+			continue
+		}
+		commands = append(commands, fmt.Sprintf("set -gx %s %s", c.Key, fishQuote(c.Value)))
+	}
+	return strings.Join(commands, "\n")
+}
+
+// fishQuote quotes a value for fish, where only backslash and the single
+// quote itself are special inside single quotes
+func fishQuote(value string) string {
+	if isSafe(value, wordChars+"-+=:,./@") {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// Quote quotes value for fish unless it is made of safe characters only
+func (e *Fish) Quote(value string) string {
+	return fishQuote(value)
+}
+
+// ValidKey accepts fish variable names: a letter or underscore followed by
+// letters, digits or underscores
+func (e *Fish) ValidKey(key string) bool {
+	return isIdentifier(key)
+}
@@ -0,0 +1,80 @@
+package emitter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Nushell emits the changes as data for the nushell hook, which applies
+// them with hide-env and load-env
+type Nushell struct{}
+
+// Emit renders changes as a single-line JSON object: "unset" lists the
+// variables to remove and "set" holds the variables to set, in order. The
+// hook reads it with from json, so no output is ever evaluated as code.
+func (e *Nushell) Emit(changes []Change) string {
+	unset, set := []string{}, []string{}
+	for _, c := range changes {
+		if !e.ValidKey(c.Key) {
+			continue
+		}
+		if c.Unset {
+			unset = append(unset, jsonString(c.Key))
+			continue
+		}
+		set = append(set, jsonString(c.Key)+":"+jsonString(c.Value))
+	}
+	if len(unset) == 0 && len(set) == 0 {
+		return ""
+	}
+	return `{"unset":[` + strings.Join(unset, ",") + `],"set":{` + strings.Join(set, ",") + `}}`
+}
+
+// jsonString encodes value as a JSON string
+func jsonString(value string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	// Encoding a string cannot fail
+	_ = enc.Encode(value)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// nushellQuote writes a double-quoted nushell string, escaping quotes,
+// backslashes and control characters
+func nushellQuote(value string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u{%x}`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Quote double-quotes value
+func (e *Nushell) Quote(value string) string {
+	return nushellQuote(value)
+}
+
+// ValidKey accepts the same names as the other shells. Nushell quotes
+// keys, but hide-env and $env access are simpler with plain names.
+func (e *Nushell) ValidKey(key string) bool {
+	return isIdentifier(key)
+}
@@ -0,0 +1,44 @@
+package emitter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Posix emits export/unset commands for bash, zsh and other POSIX shells
+type Posix struct{}
+
+// Emit renders changes as one export or unset command per line. Values are
+// always single-quoted.
+func (e *Posix) Emit(changes []Change) string {
+	commands := make([]string, 0, len(changes))
+	for _, c := range changes {
+		if !e.ValidKey(c.Key) {
+			continue
+		}
+		if c.Unset {
+			commands = append(commands, fmt.Sprintf("unset %s", c.Key))
+			continue
+		}
+		commands = append(commands, fmt.Sprintf("export %s=%s", c.Key, posixQuote(c.Value)))
+	}
+	return strings.Join(commands, "\n")
+}
+
+// ValidKey accepts POSIX shell names: a letter or underscore followed by
+// letters, digits or underscores
+func (e *Posix) ValidKey(key string) bool {
+	return isIdentifier(key)
+}
+
+// Quote single-quotes value
+func (e *Posix) Quote(value string) string {
+	return posixQuote(value)
+}
+
+// posixQuote wraps a value in single quotes, inside which no character is
+// special. A single quote in the value closes the quotes, is written
+// inside double quotes and opens them again.
+func posixQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,53 @@
+package emitter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PowerShell emits $env: assignments and Remove-Item commands for pwsh
+type PowerShell struct{}
+
+// Emit renders changes as one statement per line
+func (e *PowerShell) Emit(changes []Change) string {
+	commands := make([]string, 0, len(changes))
+	for _, c := range changes {
+		if !e.ValidKey(c.Key) {
+			continue
+		}
+		if c.Unset {
+			commands = append(commands, fmt.Sprintf("Remove-Item -Path Env:%s -ErrorAction SilentlyContinue", c.Key))
+			continue
+		}
+		commands = append(commands, fmt.Sprintf("$env:%s = %s", c.Key, powerShellQuote(c.Value)))
+	}
+	return strings.Join(commands, "\n")
+}
+
+// powerShellQuote wraps a value in single quotes, inside which only the
+// single quote is special and is escaped by doubling it. PowerShell also
+// treats the typographic single quotes as quote characters.
+func powerShellQuote(value string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range value {
+		switch r {
+		case '\'', '‘', '’', '‚', '‛':
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// Quote single-quotes value
+func (e *PowerShell) Quote(value string) string {
+	return powerShellQuote(value)
+}
+
+// ValidKey accepts names usable in $env:NAME without braces: a letter or
+// underscore followed by letters, digits or underscores
+func (e *PowerShell) ValidKey(key string) bool {
+	return isIdentifier(key)
+}
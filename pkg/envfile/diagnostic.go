@@ -0,0 +1,60 @@
+package envfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity classifies a Diagnostic
+type Severity int
+
+const (
+	// SeverityWarning marks suspicious but usable input
+	SeverityWarning Severity = iota
+	// SeverityError marks input that could not be loaded
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Diagnostic describes a problem found at a position in a .env file
+type Diagnostic struct {
+	File     string
+	Line     int
+	Column   int
+	Severity Severity
+	Message  string
+}
+
+// String formats the diagnostic like a compiler message:
+// file:line:column: severity: message
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, d.Message)
+}
+
+// Diagnostics is a list of problems found while parsing. It implements
+// error so that parsers can return it when a file contains errors.
+type Diagnostics []Diagnostic
+
+// HasErrors reports whether any diagnostic has error severity
+func (ds Diagnostics) HasErrors() bool {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (ds Diagnostics) Error() string {
+	lines := make([]string, len(ds))
+	for i, d := range ds {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,95 @@
+package envfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DiscoverOptions controls how Discover walks up the directory tree
+type DiscoverOptions struct {
+	// Boundary is the last directory searched. When the start directory is
+	// not inside Boundary, only the start directory is searched. An empty
+	// Boundary means the filesystem root.
+	Boundary string
+	// Markers stops the walk at the first directory containing one of
+	// these names, such as ".git"
+	Markers []string
+}
+
+// Discover walks from dir up to the boundary and returns the path of every
+// regular file with one of the given names found on the way. The result is
+// ordered from the farthest directory to the nearest, and within a
+// directory in the order of names, so that merging the files in order lets
+// the nearest one win.
+func Discover(dir string, names []string, opts DiscoverOptions) ([]string, error) {
+	candidates, err := Candidates(dir, names, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []string
+	for _, path := range candidates {
+		info, err := os.Stat(path)
+		if err == nil && info.Mode().IsRegular() {
+			found = append(found, path)
+		} else if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+// Candidates returns every path Discover looks at, whether or not a file
+// exists there, in the same order
+func Candidates(dir string, names []string, opts DiscoverOptions) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	boundary := string(filepath.Separator)
+	if opts.Boundary != "" {
+		if boundary, err = filepath.Abs(opts.Boundary); err != nil {
+			return nil, err
+		}
+	}
+	inside := isWithin(dir, boundary)
+
+	// Collect directories nearest first, then list them farthest first
+	var dirs []string
+	for {
+		dirs = append(dirs, dir)
+
+		parent := filepath.Dir(dir)
+		if !inside || dir == boundary || parent == dir || hasMarker(dir, opts.Markers) {
+			break
+		}
+		dir = parent
+	}
+
+	var paths []string
+	for i := len(dirs) - 1; i >= 0; i-- {
+		for _, name := range names {
+			paths = append(paths, filepath.Join(dirs[i], name))
+		}
+	}
+	return paths, nil
+}
+
+// isWithin reports whether dir is root or one of its descendants
+func isWithin(dir, root string) bool {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func hasMarker(dir string, markers []string) bool {
+	for _, marker := range markers {
+		if _, err := os.Lstat(filepath.Join(dir, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package envfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscover(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envfile-discover-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	tempDir, err = filepath.EvalSymlinks(tempDir)
+	assert.NoError(t, err)
+
+	// tempDir/.env
+	// tempDir/repo/.git
+	// tempDir/repo/.env
+	// tempDir/repo/services/api/.env
+	// tempDir/repo/services/.env is a directory and must be ignored
+	repo := filepath.Join(tempDir, "repo")
+	api := filepath.Join(repo, "services", "api")
+	assert.NoError(t, os.MkdirAll(api, 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(repo, ".git"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(repo, "services", ".env"), 0755))
+	for _, dir := range []string{tempDir, repo, api} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".env"), []byte("A=1\n"), 0644))
+	}
+
+	testCases := []struct {
+		name     string
+		start    string
+		opts     DiscoverOptions
+		expected []string
+	}{
+		{
+			name:  "Up to boundary",
+			start: api,
+			opts:  DiscoverOptions{Boundary: tempDir},
+			expected: []string{
+				filepath.Join(tempDir, ".env"),
+				filepath.Join(repo, ".env"),
+				filepath.Join(api, ".env"),
+			},
+		},
+		{
+			name:  "Stops at marker",
+			start: api,
+			opts:  DiscoverOptions{Boundary: tempDir, Markers: []string{".git", ".hg"}},
+			expected: []string{
+				filepath.Join(repo, ".env"),
+				filepath.Join(api, ".env"),
+			},
+		},
+		{
+			name:  "Boundary is inclusive",
+			start: api,
+			opts:  DiscoverOptions{Boundary: repo},
+			expected: []string{
+				filepath.Join(repo, ".env"),
+				filepath.Join(api, ".env"),
+			},
+		},
+		{
+			name:     "Outside boundary only searches start",
+			start:    repo,
+			opts:     DiscoverOptions{Boundary: api},
+			expected: []string{filepath.Join(repo, ".env")},
+		},
+		{
+			name:     "Start without file",
+			start:    filepath.Join(repo, "services"),
+			opts:     DiscoverOptions{Boundary: repo},
+			expected: []string{filepath.Join(repo, ".env")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			found, err := Discover(tc.start, []string{".env"}, tc.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, found)
+		})
+	}
+}
+
+func TestDiscover_MultipleNames(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envfile-discover-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	tempDir, err = filepath.EvalSymlinks(tempDir)
+	assert.NoError(t, err)
+
+	sub := filepath.Join(tempDir, "sub")
+	assert.NoError(t, os.MkdirAll(sub, 0755))
+	for _, path := range []string{
+		filepath.Join(tempDir, ".env"),
+		filepath.Join(tempDir, ".env.local"),
+		filepath.Join(sub, ".env"),
+		filepath.Join(sub, ".env.prod"),
+	} {
+		assert.NoError(t, ioutil.WriteFile(path, []byte("A=1\n"), 0644))
+	}
+
+	found, err := Discover(sub, []string{".env", ".env.local", ".env.prod"}, DiscoverOptions{Boundary: tempDir})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(tempDir, ".env"),
+		filepath.Join(tempDir, ".env.local"),
+		filepath.Join(sub, ".env"),
+		filepath.Join(sub, ".env.prod"),
+	}, found)
+}
+
+func TestCandidates(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envfile-discover-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	tempDir, err = filepath.EvalSymlinks(tempDir)
+	assert.NoError(t, err)
+
+	// Only tempDir/.env exists, but every searched path is listed
+	api := filepath.Join(tempDir, "api")
+	assert.NoError(t, os.MkdirAll(api, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tempDir, ".env"), []byte("A=1\n"), 0644))
+
+	paths, err := Candidates(api, []string{".env", ".env.local"}, DiscoverOptions{Boundary: tempDir})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(tempDir, ".env"),
+		filepath.Join(tempDir, ".env.local"),
+		filepath.Join(api, ".env"),
+		filepath.Join(api, ".env.local"),
+	}, paths)
+}
@@ -0,0 +1,377 @@
+package envfile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/username/envtool/pkg/atomicfile"
+)
+
+// Position is a location in .env source. Line and Column are 1-based,
+// Offset is a 0-based byte offset.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// Span is the half-open range of source text [Start, End)
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// NodeKind identifies what a Node holds
+type NodeKind int
+
+const (
+	// BlankNode is a line holding only whitespace
+	BlankNode NodeKind = iota
+	// CommentNode is a line holding only a comment
+	CommentNode
+	// EntryNode is a KEY=value assignment
+	EntryNode
+	// InvalidNode is a line that could not be parsed
+	InvalidNode
+)
+
+// Node is one logical line of a .env file. Entries holding multiline
+// quoted values span several physical lines.
+type Node struct {
+	Kind NodeKind
+	Span Span
+	// Text is the exact source of the node, including its line terminator
+	Text string
+
+	// Export is set when the entry has an "export" prefix
+	Export bool
+	// Key is the entry's variable name
+	Key string
+	// KeyPos is the position of the key in the source
+	KeyPos Position
+	// RawValue is the value as written, without surrounding quotes
+	RawValue string
+	// Quote is '\'', '"' or 0 for unquoted values
+	Quote byte
+	// Comment is the comment text starting at '#', if any
+	Comment string
+}
+
+// Document is an ordered, position-preserving view of a .env file.
+// Writing a document that has not been modified reproduces its source
+// byte for byte.
+type Document struct {
+	// Name is the file name used in diagnostics
+	Name  string
+	Nodes []*Node
+	// Diagnostics holds the syntax problems found while parsing
+	Diagnostics Diagnostics
+}
+
+// ParseDocument parses .env source. Syntax problems do not stop parsing;
+// they are recorded in the document's Diagnostics.
+func ParseDocument(name string, src []byte) *Document {
+	nodes, diags := scanNodes(name, string(src))
+	doc := &Document{Name: name, Nodes: nodes, Diagnostics: diags}
+
+	firstLine := make(map[string]int)
+	for _, n := range doc.Entries() {
+		if line, ok := firstLine[n.Key]; ok {
+			doc.Diagnostics = append(doc.Diagnostics, Diagnostic{
+				File:     name,
+				Line:     n.KeyPos.Line,
+				Column:   n.KeyPos.Column,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("duplicate key %q, first defined on line %d", n.Key, line),
+			})
+		} else {
+			firstLine[n.Key] = n.KeyPos.Line
+		}
+	}
+	sortDiagnostics(doc.Diagnostics)
+	return doc
+}
+
+// ReadDocument reads and parses the .env file at path
+func ReadDocument(path string) (*Document, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDocument(path, data), nil
+}
+
+// WriteFile atomically replaces the file at path with the serialized
+// document. The content is written to a temporary file in the same
+// directory, synced and renamed over the target, so readers never see a
+// partial file. Existing files keep their permissions and symlinks are
+// followed; new files are created with mode 0644.
+func (d *Document) WriteFile(path string) error {
+	return atomicfile.WriteFile(path, []byte(d.String()), 0644)
+}
+
+// Entries returns the document's assignments in file order
+func (d *Document) Entries() []*Node {
+	var entries []*Node
+	for _, n := range d.Nodes {
+		if n.Kind == EntryNode {
+			entries = append(entries, n)
+		}
+	}
+	return entries
+}
+
+// String serializes the document back to .env source
+func (d *Document) String() string {
+	var b strings.Builder
+	for _, n := range d.Nodes {
+		b.WriteString(n.Text)
+	}
+	return b.String()
+}
+
+// Bytes serializes the document back to .env source
+func (d *Document) Bytes() []byte {
+	return []byte(d.String())
+}
+
+// Values expands the document's entries into a map of variables. Later
+// assignments to the same key override earlier ones. lookupEnv resolves
+// references to keys the document does not define; nil means
+// os.LookupEnv. Entries that cannot be expanded are left out and reported
+// in the returned diagnostics.
+func (d *Document) Values(lookupEnv func(string) (string, bool)) (map[string]string, Diagnostics) {
+	return d.values(lookupEnv, nil)
+}
+
+// values is Values, running $(command) substitutions with run if it is
+// not nil
+func (d *Document) values(lookupEnv func(string) (string, bool), run func(Command) (string, error)) (map[string]string, Diagnostics) {
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+
+	entries := d.Entries()
+	x := newExpander(entries, lookupEnv)
+	x.file, x.run = d.Name, run
+	values := make(map[string]string)
+	var diags Diagnostics
+	reported := make(map[*expandError]bool)
+	for i, n := range entries {
+		value, err := x.resolve(i)
+		if err != nil {
+			ee := err.(*expandError)
+			if !reported[ee] {
+				reported[ee] = true
+				origin := entries[ee.index]
+				diags = append(diags, Diagnostic{
+					File:     d.Name,
+					Line:     origin.KeyPos.Line,
+					Column:   origin.KeyPos.Column,
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("cannot expand %s: %s", origin.Key, ee.msg),
+				})
+			}
+			delete(values, n.Key)
+			continue
+		}
+		values[n.Key] = value
+	}
+	return values, diags
+}
+
+// sortDiagnostics orders diagnostics by position
+func sortDiagnostics(diags Diagnostics) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].Line != diags[j].Line {
+			return diags[i].Line < diags[j].Line
+		}
+		return diags[i].Column < diags[j].Column
+	})
+}
+
+// Lookup returns the last entry assigning key, or nil if there is none
+func (d *Document) Lookup(key string) *Node {
+	for i := len(d.Nodes) - 1; i >= 0; i-- {
+		if n := d.Nodes[i]; n.Kind == EntryNode && n.Key == key {
+			return n
+		}
+	}
+	return nil
+}
+
+// Set assigns a literal value to key. The last existing assignment is
+// rewritten in place, keeping its export prefix and inline comment;
+// otherwise a new entry is appended. Spans of edited nodes no longer refer
+// to the original source.
+func (d *Document) Set(key, value string) {
+	if n := d.Lookup(key); n != nil {
+		n.SetValue(value)
+		return
+	}
+
+	ending := "\n"
+	for _, n := range d.Nodes {
+		if e := lineEnding(n.Text); e != "" {
+			ending = e
+			break
+		}
+	}
+	if len(d.Nodes) > 0 {
+		if last := d.Nodes[len(d.Nodes)-1]; lineEnding(last.Text) == "" {
+			last.Text += ending
+		}
+	}
+	raw, quote := QuoteValue(value)
+	n := &Node{Kind: EntryNode, Key: key, RawValue: raw, Quote: quote}
+	n.Text = formatEntry(n, ending)
+	d.Nodes = append(d.Nodes, n)
+}
+
+// SetValue replaces the value of an entry with a literal value, quoted as
+// needed, keeping its export prefix and inline comment. The value is
+// written on a single line, so replacing a single-line value keeps the
+// line numbers of later nodes.
+func (n *Node) SetValue(value string) {
+	n.RawValue, n.Quote = QuoteValue(value)
+	n.Text = formatEntry(n, lineEnding(n.Text))
+}
+
+// LiteralValue returns the entry's value as written, with its quotes
+// removed and, in double quotes, its backslash escapes processed.
+// References and command substitutions are kept as they are.
+func (n *Node) LiteralValue() string {
+	if n.Quote != '"' {
+		return n.RawValue
+	}
+	var b strings.Builder
+	for i := 0; i < len(n.RawValue); i++ {
+		if c := n.RawValue[i]; c == '\\' && i+1 < len(n.RawValue) {
+			i++
+			b.WriteString(unescapeChar(n.RawValue[i]))
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// SetLiteralValue replaces the value of an entry with value as it would be
+// written, so that LiteralValue returns it again. Unlike SetValue, any
+// references and command substitutions in value are expanded on load: a
+// value that needs quoting is double-quoted with only backslashes, double
+// quotes and line breaks escaped.
+func (n *Node) SetLiteralValue(value string) {
+	raw, quote := QuoteValue(value)
+	if quote != 0 {
+		var b strings.Builder
+		for i := 0; i < len(value); i++ {
+			switch c := value[i]; c {
+			case '\\', '"':
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			case '\n':
+				b.WriteString(`\n`)
+			case '\r':
+				b.WriteString(`\r`)
+			default:
+				b.WriteByte(c)
+			}
+		}
+		raw, quote = b.String(), '"'
+	}
+	n.RawValue, n.Quote = raw, quote
+	n.Text = formatEntry(n, lineEnding(n.Text))
+}
+
+// Unset removes every assignment to key and reports whether there was any
+func (d *Document) Unset(key string) bool {
+	nodes := d.Nodes[:0]
+	found := false
+	for _, n := range d.Nodes {
+		if n.Kind == EntryNode && n.Key == key {
+			found = true
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	d.Nodes = nodes
+	return found
+}
+
+// QuoteValue picks the quoting for a literal value: bare when every
+// character is safe, single quotes when the value holds no single quote or
+// line break, and double quotes with escapes otherwise. It returns the text
+// to write between the quotes and the quote character, 0 meaning none.
+func QuoteValue(value string) (raw string, quote byte) {
+	safe := true
+	for i := 0; i < len(value); i++ {
+		if !isSafeValueChar(value[i]) {
+			safe = false
+			break
+		}
+	}
+	switch {
+	case safe:
+		return value, 0
+	case !strings.ContainsAny(value, "'\n\r"):
+		return value, '\''
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\', '"', '$', '`':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), '"'
+}
+
+// formatEntry renders an entry node as source text
+func formatEntry(n *Node, ending string) string {
+	var b strings.Builder
+	if n.Export {
+		b.WriteString("export ")
+	}
+	b.WriteString(n.Key)
+	b.WriteByte('=')
+	if n.Quote != 0 {
+		b.WriteByte(n.Quote)
+	}
+	b.WriteString(n.RawValue)
+	if n.Quote != 0 {
+		b.WriteByte(n.Quote)
+	}
+	if n.Comment != "" {
+		b.WriteByte(' ')
+		b.WriteString(n.Comment)
+	}
+	b.WriteString(ending)
+	return b.String()
+}
+
+// lineEnding returns the line terminator text ends with, if any
+func lineEnding(text string) string {
+	switch {
+	case strings.HasSuffix(text, "\r\n"):
+		return "\r\n"
+	case strings.HasSuffix(text, "\n"):
+		return "\n"
+	}
+	return ""
+}
+
+func isSafeValueChar(c byte) bool {
+	return isNameChar(c) || strings.IndexByte("-./:@%+,=", c) >= 0
+}
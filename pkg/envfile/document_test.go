@@ -0,0 +1,207 @@
+package envfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocument_RoundTrip(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{name: "Empty", content: ""},
+		{name: "No trailing newline", content: "FOO=bar"},
+		{name: "Comments and blanks", content: "# header\n\n  \nFOO=bar # note\n\t# indented\n"},
+		{name: "Quoting", content: "export A='x y'\nB=\"multi\nline\\n\"  # c\nC=plain\n"},
+		{name: "CRLF", content: "A=1\r\nB=\"2\"\r\n\r\n"},
+		{name: "Invalid lines", content: "NOT VALID\n=x\n1A=\"multi\nline\"\nA=\"unterminated\n"},
+		{name: "Duplicates", content: "A=1\nB=2\nA=3\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc := ParseDocument(".env", []byte(tc.content))
+			assert.Equal(t, tc.content, doc.String())
+			assert.Equal(t, []byte(tc.content), doc.Bytes())
+		})
+	}
+}
+
+func TestDocument_Nodes(t *testing.T) {
+	content := "# header\n\nexport FOO=\"a\nb\" # note\nBAD LINE\nBAR=baz\n"
+	doc := ParseDocument(".env", []byte(content))
+
+	if !assert.Len(t, doc.Nodes, 5) {
+		return
+	}
+	kinds := []NodeKind{CommentNode, BlankNode, EntryNode, InvalidNode, EntryNode}
+	for i, kind := range kinds {
+		assert.Equal(t, kind, doc.Nodes[i].Kind, "node %d", i)
+	}
+
+	header := doc.Nodes[0]
+	assert.Equal(t, "# header", header.Comment)
+	assert.Equal(t, Span{
+		Start: Position{Offset: 0, Line: 1, Column: 1},
+		End:   Position{Offset: 9, Line: 2, Column: 1},
+	}, header.Span)
+
+	foo := doc.Nodes[2]
+	assert.Equal(t, "export FOO=\"a\nb\" # note\n", foo.Text)
+	assert.True(t, foo.Export)
+	assert.Equal(t, "FOO", foo.Key)
+	assert.Equal(t, Position{Offset: 17, Line: 3, Column: 8}, foo.KeyPos)
+	assert.Equal(t, "a\nb", foo.RawValue)
+	assert.Equal(t, byte('"'), foo.Quote)
+	assert.Equal(t, "# note", foo.Comment)
+	assert.Equal(t, Position{Offset: 10, Line: 3, Column: 1}, foo.Span.Start)
+	assert.Equal(t, Position{Offset: 34, Line: 5, Column: 1}, foo.Span.End)
+
+	assert.Equal(t, "BAD LINE\n", doc.Nodes[3].Text)
+
+	entries := doc.Entries()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "FOO", entries[0].Key)
+	assert.Equal(t, "BAR", entries[1].Key)
+
+	assert.Len(t, doc.Diagnostics, 1)
+	assert.Equal(t, 5, doc.Diagnostics[0].Line)
+}
+
+func TestDocument_Values(t *testing.T) {
+	doc := ParseDocument(".env", []byte("A=1\nB=${A}2\nA=3\nC='$A'\n"))
+	values, diags := doc.Values(func(string) (string, bool) { return "", false })
+	assert.Empty(t, diags)
+	assert.Equal(t, map[string]string{"A": "3", "B": "12", "C": "$A"}, values)
+}
+
+func TestDocument_SetUnset(t *testing.T) {
+	content := "# config\r\nexport FOO=old # keep me\r\nBAR=1\r\nBAR=2\r\nBAZ=3\r\nLAST=x"
+	doc := ParseDocument(".env", []byte(content))
+
+	doc.Set("FOO", "new value")
+	doc.Set("BAR", "two")
+	doc.Set("NEW", "x")
+	assert.True(t, doc.Unset("BAZ"))
+	assert.False(t, doc.Unset("MISSING"))
+
+	assert.Equal(t, "# config\r\nexport FOO='new value' # keep me\r\nBAR=1\r\nBAR=two\r\nLAST=x\r\nNEW=x\r\n", doc.String())
+}
+
+func TestNode_SetValue(t *testing.T) {
+	doc := ParseDocument(".env", []byte("export A=x # note\nB=\"multi\nline\"\nC=1\n"))
+	entries := doc.Entries()
+	entries[0].SetValue("it's")
+	entries[1].SetValue("one\ntwo")
+	assert.Equal(t, "export A=\"it's\" # note\nB=\"one\\ntwo\"\nC=1\n", doc.String())
+
+	values, diags := doc.Values(func(string) (string, bool) { return "", false })
+	assert.Empty(t, diags)
+	assert.Equal(t, map[string]string{"A": "it's", "B": "one\ntwo", "C": "1"}, values)
+}
+
+func TestNode_LiteralValue(t *testing.T) {
+	doc := ParseDocument(".env", []byte("A=${HOME}/x\nB='$HOME \\n'\nC=\"say \\\"hi\\\"\\n$HOME\"\n"))
+	assert.Empty(t, doc.Diagnostics)
+	assert.Equal(t, "${HOME}/x", doc.Lookup("A").LiteralValue())
+	assert.Equal(t, `$HOME \n`, doc.Lookup("B").LiteralValue())
+	assert.Equal(t, "say \"hi\"\n$HOME", doc.Lookup("C").LiteralValue())
+}
+
+func TestNode_SetLiteralValue(t *testing.T) {
+	doc := ParseDocument(".env", []byte("A=1 # a\nB=2\nC=3\nD=4\n"))
+	doc.Lookup("A").SetLiteralValue("${HOME}/x")
+	doc.Lookup("B").SetLiteralValue("plain")
+	doc.Lookup("C").SetLiteralValue("say \"hi\" \\ $(id -u)\n")
+	doc.Lookup("D").SetLiteralValue("it's")
+	assert.Equal(t, "A=\"${HOME}/x\" # a\nB=plain\nC=\"say \\\"hi\\\" \\\\ $(id -u)\\n\"\nD=\"it's\"\n", string(doc.Bytes()))
+
+	// The values read back as set, with references expanded on load
+	doc = ParseDocument(".env", doc.Bytes())
+	assert.Empty(t, doc.Diagnostics)
+	assert.Equal(t, "${HOME}/x", doc.Lookup("A").LiteralValue())
+	assert.Equal(t, "say \"hi\" \\ $(id -u)\n", doc.Lookup("C").LiteralValue())
+	values, diags := doc.Values(func(name string) (string, bool) { return "/home/u", name == "HOME" })
+	assert.Empty(t, diags)
+	assert.Equal(t, "/home/u/x", values["A"])
+	assert.Equal(t, "it's", values["D"])
+}
+
+func TestQuoteValue(t *testing.T) {
+	values := []string{
+		"",
+		"plain",
+		"postgres://user@host:5432/db?x=1",
+		"with space",
+		"a # b",
+		"$HOME and ${VAR}",
+		"it's",
+		"multi\nline",
+		`back\slash "quotes" $dollar` + "`tick`'",
+	}
+
+	for _, value := range values {
+		doc := ParseDocument(".env", nil)
+		doc.Set("KEY", value)
+
+		reparsed := ParseDocument(".env", doc.Bytes())
+		assert.Empty(t, reparsed.Diagnostics, "value %q", value)
+		parsed, diags := reparsed.Values(func(string) (string, bool) { return "expanded", true })
+		assert.Empty(t, diags, "value %q", value)
+		assert.Equal(t, value, parsed["KEY"], "source %q", doc.String())
+	}
+
+	raw, quote := QuoteValue("plain")
+	assert.Equal(t, "plain", raw)
+	assert.Equal(t, byte(0), quote)
+	raw, quote = QuoteValue("a b")
+	assert.Equal(t, "a b", raw)
+	assert.Equal(t, byte('\''), quote)
+}
+
+func TestDocument_WriteFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envfile-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	target := filepath.Join(tempDir, "real.env")
+	link := filepath.Join(tempDir, ".env")
+	assert.NoError(t, ioutil.WriteFile(target, []byte("A=1\n"), 0600))
+	assert.NoError(t, os.Symlink(target, link))
+
+	doc, err := ReadDocument(link)
+	assert.NoError(t, err)
+	doc.Set("B", "2")
+	assert.NoError(t, doc.WriteFile(link))
+
+	content, err := ioutil.ReadFile(target)
+	assert.NoError(t, err)
+	assert.Equal(t, "A=1\nB=2\n", string(content))
+
+	info, err := os.Stat(target)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	info, err = os.Lstat(link)
+	assert.NoError(t, err)
+	assert.True(t, info.Mode()&os.ModeSymlink != 0, "symlink should be preserved")
+
+	// No temporary files are left behind
+	files, err := ioutil.ReadDir(tempDir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	// New files get the default mode
+	created := filepath.Join(tempDir, "new.env")
+	assert.NoError(t, ParseDocument(created, nil).WriteFile(created))
+	info, err = os.Stat(created)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
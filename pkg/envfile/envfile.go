@@ -1,59 +1,73 @@
 package envfile
 
-import (
-	"bufio"
-	"os"
-	"strings"
-)
-
 // Parser handles reading and parsing .env files
 type Parser interface {
 	Parse(path string) (map[string]string, error)
 }
 
 // DefaultParser implements the Parser interface
-type DefaultParser struct{}
+type DefaultParser struct {
+	// LookupEnv resolves variable references that are not defined in the
+	// file itself. It defaults to os.LookupEnv.
+	LookupEnv func(key string) (string, bool)
+	// ReadDocument reads and parses a file, for example to decrypt it
+	// first. It defaults to the package's ReadDocument.
+	ReadDocument func(path string) (*Document, error)
+	// RunCommand returns the output of a $(command) substitution. When it
+	// is nil, which is the default, $(...) is kept literally.
+	RunCommand func(cmd Command) (string, error)
+}
 
-// Parse reads and parses a .env file at the given path
+// Parse reads and parses a .env file at the given path.
+//
+// The file follows the common dotenv grammar used by docker-compose and
+// python-dotenv: an optional "export" prefix, single quotes that keep text
+// literally, double quotes that process escapes, quoted values spanning
+// several lines and inline comments outside quotes. Later assignments to
+// the same key override earlier ones.
+//
+// Unquoted and double-quoted values may reference other variables with
+// $VAR, ${VAR}, ${VAR:-default}, ${VAR:?error} or ${VAR:+alt}. References
+// resolve to keys defined earlier in the file, then to the environment.
+// They may also hold $(command) substitutions, which are only run when
+// RunCommand is set.
+//
+// If the file contains errors, Parse returns the entries it could read
+// together with a Diagnostics error describing the rest.
 func (p *DefaultParser) Parse(path string) (map[string]string, error) {
-	file, err := os.Open(path)
+	envVars, diags, err := p.parse(path)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	if diags.HasErrors() {
+		return envVars, diags
+	}
+	return envVars, nil
+}
+
+// Check parses the .env file at path and returns every problem found in
+// it, including warnings that Parse does not report
+func (p *DefaultParser) Check(path string) (Diagnostics, error) {
+	_, diags, err := p.parse(path)
+	return diags, err
+}
 
-	envVars := make(map[string]string)
-	scanner := bufio.NewScanner(file)
-	
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		
-		// Split on first equals sign
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
-		
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		
-		// Remove quotes if present
-		if (strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"")) ||
-		   (strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'")) {
-			value = value[1 : len(value)-1]
-		}
-		
-		envVars[key] = value
+func (p *DefaultParser) parse(path string) (map[string]string, Diagnostics, error) {
+	doc, err := p.readDocument(path)
+	if err != nil {
+		return nil, nil, err
 	}
-	
-	if err := scanner.Err(); err != nil {
-		return nil, err
+
+	envVars, expandDiags := doc.values(p.LookupEnv, p.RunCommand)
+	diags := append(append(Diagnostics{}, doc.Diagnostics...), expandDiags...)
+	sortDiagnostics(diags)
+	return envVars, diags, nil
+}
+
+// readDocument reads a file with the configured ReadDocument function
+func (p *DefaultParser) readDocument(path string) (*Document, error) {
+	if p.ReadDocument != nil {
+		return p.ReadDocument(path)
 	}
-	
-	return envVars, nil
-}
\ No newline at end of file
+	return ReadDocument(path)
+}
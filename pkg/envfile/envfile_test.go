@@ -74,6 +74,85 @@ SPACE_AFTER=value with space after
 				"SPACE_AFTER":  "value with space after",
 			},
 		},
+		{
+			name: "Export prefix",
+			content: `
+export FOO=bar
+export   BAR="baz"
+export=literal
+`,
+			expected: map[string]string{
+				"FOO":    "bar",
+				"BAR":    "baz",
+				"export": "literal",
+			},
+		},
+		{
+			name: "Inline comments",
+			content: `
+FOO=bar # trailing comment
+HASH=a#b
+QUOTED="a # b" # note
+SINGLE='c # d'	# note
+EMPTY= # nothing
+`,
+			expected: map[string]string{
+				"FOO":    "bar",
+				"HASH":   "a#b",
+				"QUOTED": "a # b",
+				"SINGLE": "c # d",
+				"EMPTY":  "",
+			},
+		},
+		{
+			name: "Escapes",
+			content: `
+DOUBLE="line1\nline2\t\"quoted\" \\ \$HOME"
+SINGLE='line1\nline2'
+UNQUOTED=line1\nline2
+UNKNOWN="keep \q"
+`,
+			expected: map[string]string{
+				"DOUBLE":   "line1\nline2\t\"quoted\" \\ $HOME",
+				"SINGLE":   `line1\nline2`,
+				"UNQUOTED": `line1\nline2`,
+				"UNKNOWN":  `keep \q`,
+			},
+		},
+		{
+			name: "Multiline values",
+			content: `
+PEM="-----BEGIN KEY-----
+abc
+-----END KEY-----"
+SINGLE='one
+two'
+AFTER=after
+`,
+			expected: map[string]string{
+				"PEM":    "-----BEGIN KEY-----\nabc\n-----END KEY-----",
+				"SINGLE": "one\ntwo",
+				"AFTER":  "after",
+			},
+		},
+		{
+			name:    "CRLF line endings",
+			content: "FOO=bar\r\nBAZ=\"qux\"\r\n",
+			expected: map[string]string{
+				"FOO": "bar",
+				"BAZ": "qux",
+			},
+		},
+		{
+			name: "Later assignments override earlier ones",
+			content: `
+FOO=first
+FOO=second
+`,
+			expected: map[string]string{
+				"FOO": "second",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -99,4 +178,72 @@ func TestParse_FileNotExist(t *testing.T) {
 	result, err := parser.Parse("/nonexistent/file.env")
 	assert.Error(t, err)
 	assert.Nil(t, result)
-}
\ No newline at end of file
+}
+
+func TestParse_Diagnostics(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envfile-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	envPath := filepath.Join(tempDir, ".env")
+	content := `FOO=ok
+NOT AN ASSIGNMENT
+  =missing
+1BAD=x
+BAZ="quoted" trailing
+FOO=again
+BAR="unterminated
+`
+	err = ioutil.WriteFile(envPath, []byte(content), 0644)
+	assert.NoError(t, err)
+
+	parser := &DefaultParser{}
+	result, err := parser.Parse(envPath)
+	assert.Equal(t, map[string]string{
+		"FOO": "again",
+		"BAR": `"unterminated`,
+		"BAZ": "quoted",
+	}, result)
+
+	diags, ok := err.(Diagnostics)
+	if !assert.True(t, ok, "expected Diagnostics error, got %v", err) {
+		return
+	}
+	assert.True(t, diags.HasErrors())
+	assert.Equal(t, envPath+":2:5: error: expected '=' after \"NOT\"\n"+
+		envPath+":3:3: error: missing key before '='\n"+
+		envPath+":4:1: error: invalid key name \"1BAD\"\n"+
+		envPath+":5:14: error: unexpected text after quoted value\n"+
+		envPath+":6:1: warning: duplicate key \"FOO\", first defined on line 1\n"+
+		envPath+":7:5: error: unterminated quoted value", err.Error())
+
+	all, err := parser.Check(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, diags, all)
+	assert.Equal(t, Diagnostic{
+		File:     envPath,
+		Line:     6,
+		Column:   1,
+		Severity: SeverityWarning,
+		Message:  `duplicate key "FOO", first defined on line 1`,
+	}, all[4])
+}
+
+func TestParse_WarningsOnly(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envfile-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	envPath := filepath.Join(tempDir, ".env")
+	err = ioutil.WriteFile(envPath, []byte("FOO=1\nFOO=2\n"), 0644)
+	assert.NoError(t, err)
+
+	parser := &DefaultParser{}
+	result, err := parser.Parse(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"FOO": "2"}, result)
+}
@@ -0,0 +1,330 @@
+package envfile
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// expandError reports a value that could not be expanded. It records the
+// entry where the problem was found so that entries depending on it do not
+// report it again.
+type expandError struct {
+	index int
+	msg   string
+}
+
+func (e *expandError) Error() string {
+	return e.msg
+}
+
+// resolution states of an entry during expansion
+const (
+	unresolved = iota
+	resolving
+	resolved
+	failed
+)
+
+// expander expands ${VAR} style references in the values of a file.
+//
+// A reference resolves to the nearest earlier definition of the key in the
+// same file, then to the process environment; definitions later in the
+// file are not seen. Definitions are expanded on demand, and an entry that
+// is reached again while it is being expanded is reported as a cycle
+// rather than recursed into.
+//
+// $(command) substitutions are only run when run is set; otherwise they
+// are kept literally.
+type expander struct {
+	entries   []*Node
+	values    []string
+	state     []int
+	errs      []*expandError
+	stack     []string
+	lookupEnv func(string) (string, bool)
+	file      string
+	run       func(Command) (string, error)
+}
+
+func newExpander(entries []*Node, lookupEnv func(string) (string, bool)) *expander {
+	return &expander{
+		entries:   entries,
+		values:    make([]string, len(entries)),
+		state:     make([]int, len(entries)),
+		errs:      make([]*expandError, len(entries)),
+		lookupEnv: lookupEnv,
+	}
+}
+
+// Command is a $(command) substitution in a value
+type Command struct {
+	// Text is the command between the parentheses
+	Text string
+	// File is the .env file holding the command
+	File string
+	// Dir is the directory of File, where the command should run
+	Dir string
+	// Watch lists the files named by a "# watch: FILE..." comment on the
+	// entry, relative to Dir. The output of the command is expected to
+	// stay the same as long as these files do not change.
+	Watch []string
+}
+
+// resolve returns the expanded value of the i-th entry
+func (x *expander) resolve(i int) (string, error) {
+	e := x.entries[i]
+	switch x.state[i] {
+	case resolved:
+		return x.values[i], nil
+	case failed:
+		return "", x.errs[i]
+	case resolving:
+		cycle := strings.Join(append(append([]string{}, x.stack...), e.Key), " -> ")
+		return "", &expandError{index: i, msg: "reference cycle " + cycle}
+	}
+
+	if e.Quote == '\'' {
+		x.values[i], x.state[i] = e.RawValue, resolved
+		return e.RawValue, nil
+	}
+
+	x.state[i] = resolving
+	x.stack = append(x.stack, e.Key)
+	value, err := expand(e.RawValue, e.Quote == '"', func(name string) (string, bool, error) {
+		return x.lookup(name, i)
+	}, x.command(e))
+	x.stack = x.stack[:len(x.stack)-1]
+	if err != nil {
+		ee, ok := err.(*expandError)
+		if !ok {
+			ee = &expandError{index: i, msg: err.Error()}
+		}
+		x.state[i], x.errs[i] = failed, ee
+		return "", ee
+	}
+	x.values[i], x.state[i] = value, resolved
+	return value, nil
+}
+
+// command returns the function running the $(...) substitutions of entry
+// e, or nil if commands are not run
+func (x *expander) command(e *Node) func(string) (string, error) {
+	if x.run == nil {
+		return nil
+	}
+	return func(text string) (string, error) {
+		return x.run(Command{
+			Text:  text,
+			File:  x.file,
+			Dir:   filepath.Dir(x.file),
+			Watch: watchFiles(e.Comment),
+		})
+	}
+}
+
+// watchFiles returns the files listed by an inline "# watch: FILE..."
+// comment
+func watchFiles(comment string) []string {
+	text := strings.TrimSpace(strings.TrimPrefix(comment, "#"))
+	if !strings.HasPrefix(text, "watch:") {
+		return nil
+	}
+	return strings.Fields(strings.TrimPrefix(text, "watch:"))
+}
+
+// lookup finds the value name refers to from the i-th entry
+func (x *expander) lookup(name string, i int) (string, bool, error) {
+	for j := i - 1; j >= 0; j-- {
+		if x.entries[j].Key == name {
+			v, err := x.resolve(j)
+			return v, true, err
+		}
+	}
+	v, ok := x.lookupEnv(name)
+	return v, ok, nil
+}
+
+// expand substitutes $VAR, ${VAR}, ${VAR:-default}, ${VAR:?error} and
+// ${VAR:+alt} references in s. The colon-less forms only test whether the
+// variable is set rather than set and non-empty. When escapes is true,
+// backslash escapes are processed as inside double quotes, so \$ yields a
+// literal dollar sign. $(command) is replaced by the output of command if
+// it is not nil, and kept literally otherwise.
+func expand(s string, escapes bool, lookup func(string) (string, bool, error), command func(string) (string, error)) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && escapes && i+1 < len(s):
+			i++
+			b.WriteString(unescapeChar(s[i]))
+		case c == '$' && i+1 < len(s) && s[i+1] == '{':
+			end, err := closingBrace(s, i+2, escapes)
+			if err != nil {
+				return "", err
+			}
+			value, err := substitute(s[i+2:end], escapes, lookup, command)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i = end
+		case c == '$' && i+1 < len(s) && s[i+1] == '(' && command != nil:
+			end, err := closingParen(s, i+2)
+			if err != nil {
+				return "", err
+			}
+			output, err := command(s[i+2 : end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(output)
+			i = end
+		case c == '$' && i+1 < len(s) && isNameStart(s[i+1]):
+			end := i + 1
+			for end < len(s) && isNameChar(s[end]) {
+				end++
+			}
+			value, _, err := lookup(s[i+1 : end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i = end - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// closingBrace returns the index of the '}' closing a ${ whose body starts
+// at start, allowing nested ${...} references inside defaults
+func closingBrace(s string, start int, escapes bool) (int, error) {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && escapes:
+			i++
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated ${ in %q", s)
+}
+
+// closingParen returns the index of the ')' closing a $( whose command
+// starts at start. Parentheses inside quotes do not count.
+func closingParen(s string, start int) (int, error) {
+	depth := 1
+	var quote byte
+	for i := start; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\\':
+			i++
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated $( in %q", s)
+}
+
+// substitute evaluates the body of a ${...} reference
+func substitute(body string, escapes bool, lookup func(string) (string, bool, error), command func(string) (string, error)) (string, error) {
+	n := 0
+	for n < len(body) && (n == 0 && isNameStart(body[n]) || n > 0 && isNameChar(body[n])) {
+		n++
+	}
+	name, rest := body[:n], body[n:]
+	if name == "" {
+		return "", fmt.Errorf("bad substitution ${%s}", body)
+	}
+
+	value, set, err := lookup(name)
+	if err != nil {
+		return "", err
+	}
+	if rest == "" {
+		return value, nil
+	}
+
+	colon := strings.HasPrefix(rest, ":")
+	if colon {
+		rest = rest[1:]
+	}
+	if rest == "" {
+		return "", fmt.Errorf("bad substitution ${%s}", body)
+	}
+	op, word := rest[0], rest[1:]
+	present := set && (!colon || value != "")
+
+	switch op {
+	case '-':
+		if present {
+			return value, nil
+		}
+		return expand(word, escapes, lookup, command)
+	case '+':
+		if !present {
+			return "", nil
+		}
+		return expand(word, escapes, lookup, command)
+	case '?':
+		if present {
+			return value, nil
+		}
+		msg, err := expand(word, escapes, lookup, command)
+		if err != nil {
+			return "", err
+		}
+		if msg == "" {
+			msg = "parameter null or not set"
+		}
+		return "", fmt.Errorf("%s: %s", name, msg)
+	}
+	return "", fmt.Errorf("bad substitution ${%s}", body)
+}
+
+// unescapeChar returns the text a backslash followed by c stands for
+// inside double quotes
+func unescapeChar(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	case 't':
+		return "\t"
+	case '\\', '"', '\'', '$', '`':
+		return string(c)
+	}
+	return "\\" + string(c)
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || c >= '0' && c <= '9'
+}
@@ -0,0 +1,32 @@
+package envfile
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+// Fingerprint summarizes a set of files without reading them: the path,
+// modification time, size and inode of each, or the fact that it is
+// missing. The fingerprint changes whenever one of the files is created,
+// removed or modified. Extra strings, such as the working directory, are
+// mixed in. The result is short enough to keep in an environment variable.
+func Fingerprint(paths []string, extra ...string) string {
+	h := sha256.New()
+	for _, s := range extra {
+		fmt.Fprintf(h, "%q\n", s)
+	}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		switch {
+		case err == nil:
+			fmt.Fprintf(h, "%q %d %d %d\n", path, info.ModTime().UnixNano(), info.Size(), fileID(info))
+		case os.IsNotExist(err):
+			fmt.Fprintf(h, "%q -\n", path)
+		default:
+			fmt.Fprintf(h, "%q ?\n", path)
+		}
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:12])
+}
@@ -0,0 +1,43 @@
+package envfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envfile-fingerprint-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, ".env")
+	paths := []string{path}
+
+	missing := Fingerprint(paths, "dir")
+	assert.Len(t, missing, 16)
+	assert.Equal(t, missing, Fingerprint(paths, "dir"))
+	assert.NotEqual(t, missing, Fingerprint(paths, "other"))
+
+	// Creating the file changes the fingerprint
+	assert.NoError(t, ioutil.WriteFile(path, []byte("A=1\n"), 0644))
+	created := Fingerprint(paths, "dir")
+	assert.NotEqual(t, missing, created)
+
+	// So does touching it, even with the same content
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path, later, later))
+	touched := Fingerprint(paths, "dir")
+	assert.NotEqual(t, created, touched)
+
+	// And replacing it with a file of another size
+	assert.NoError(t, ioutil.WriteFile(path, []byte("A=12\n"), 0644))
+	assert.NoError(t, os.Chtimes(path, later, later))
+	assert.NotEqual(t, touched, Fingerprint(paths, "dir"))
+}
@@ -0,0 +1,72 @@
+package envfile
+
+import (
+	"os"
+)
+
+// Origin records where a variable was defined
+type Origin struct {
+	File string
+	Line int
+}
+
+// Merged is the result of loading several .env files in order
+type Merged struct {
+	// Values maps each variable to its final value
+	Values map[string]string
+	// Origins maps each variable to the assignment that set its value
+	Origins map[string]Origin
+	// Files lists the files that were read, in order
+	Files []string
+}
+
+// ParseFiles reads the .env files at paths in order and merges them, so
+// that later files override earlier ones. Values in later files may
+// reference variables from earlier files. Missing files are skipped.
+//
+// If any file contains errors, ParseFiles returns the merged entries it
+// could read together with a Diagnostics error describing the rest.
+func (p *DefaultParser) ParseFiles(paths []string) (*Merged, error) {
+	lookupEnv := p.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+
+	merged := &Merged{
+		Values:  make(map[string]string),
+		Origins: make(map[string]Origin),
+	}
+	lookup := func(key string) (string, bool) {
+		if v, ok := merged.Values[key]; ok {
+			return v, true
+		}
+		return lookupEnv(key)
+	}
+
+	var diags Diagnostics
+	for _, path := range paths {
+		doc, err := p.readDocument(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		merged.Files = append(merged.Files, path)
+
+		values, expandDiags := doc.values(lookup, p.RunCommand)
+		fileDiags := append(append(Diagnostics{}, doc.Diagnostics...), expandDiags...)
+		sortDiagnostics(fileDiags)
+		diags = append(diags, fileDiags...)
+		for _, n := range doc.Entries() {
+			if value, ok := values[n.Key]; ok {
+				merged.Values[n.Key] = value
+				merged.Origins[n.Key] = Origin{File: path, Line: n.KeyPos.Line}
+			}
+		}
+	}
+
+	if diags.HasErrors() {
+		return merged, diags
+	}
+	return merged, nil
+}
@@ -0,0 +1,82 @@
+package envfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFiles(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envfile-merge-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	base := filepath.Join(tempDir, "base.env")
+	local := filepath.Join(tempDir, "local.env")
+	missing := filepath.Join(tempDir, "missing.env")
+	assert.NoError(t, ioutil.WriteFile(base, []byte("HOST=db\nPORT=5432\nNAME=app\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(local, []byte("# overrides\nPORT=6543\nURL=postgres://$HOST:$PORT/$NAME\n"), 0644))
+
+	parser := &DefaultParser{LookupEnv: func(string) (string, bool) { return "", false }}
+	merged, err := parser.ParseFiles([]string{base, missing, local})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"HOST": "db",
+		"PORT": "6543",
+		"NAME": "app",
+		"URL":  "postgres://db:6543/app",
+	}, merged.Values)
+	assert.Equal(t, Origin{File: base, Line: 1}, merged.Origins["HOST"])
+	assert.Equal(t, Origin{File: local, Line: 2}, merged.Origins["PORT"])
+	assert.Equal(t, Origin{File: local, Line: 3}, merged.Origins["URL"])
+	assert.Equal(t, []string{base, local}, merged.Files)
+}
+
+func TestParseFiles_Errors(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envfile-merge-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	base := filepath.Join(tempDir, "base.env")
+	broken := filepath.Join(tempDir, "broken.env")
+	assert.NoError(t, ioutil.WriteFile(base, []byte("A=1\nB=2\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(broken, []byte("A=one\nB=${MISSING:?}\nNOT VALID\n"), 0644))
+
+	parser := &DefaultParser{LookupEnv: func(string) (string, bool) { return "", false }}
+	merged, err := parser.ParseFiles([]string{base, broken})
+	diags, ok := err.(Diagnostics)
+	if assert.True(t, ok, "expected Diagnostics, got %v", err) {
+		assert.Len(t, diags, 2)
+		assert.Equal(t, broken, diags[0].File)
+		assert.Equal(t, 2, diags[0].Line)
+		assert.Equal(t, 3, diags[1].Line)
+	}
+	// The failed override keeps the earlier value
+	assert.Equal(t, map[string]string{"A": "one", "B": "2"}, merged.Values)
+}
+
+func TestParseFiles_ReadDocument(t *testing.T) {
+	var read []string
+	parser := &DefaultParser{
+		LookupEnv: func(string) (string, bool) { return "", false },
+		ReadDocument: func(path string) (*Document, error) {
+			read = append(read, path)
+			if path == "missing.env" {
+				return nil, os.ErrNotExist
+			}
+			return ParseDocument(path, []byte("FROM="+path+"\n")), nil
+		},
+	}
+	merged, err := parser.ParseFiles([]string{"a.env", "missing.env", "b.env"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.env", "missing.env", "b.env"}, read)
+	assert.Equal(t, map[string]string{"FROM": "b.env"}, merged.Values)
+	assert.Equal(t, []string{"a.env", "b.env"}, merged.Files)
+}
@@ -0,0 +1,246 @@
+package envfile
+
+import "fmt"
+
+// scanner tokenizes .env source following the common dotenv grammar:
+//
+//	[export] KEY=value        # unquoted, inline comment after whitespace
+//	KEY='literal text'        # no escapes, may span lines
+//	KEY="text with \n escapes" # escapes processed, may span lines
+type scanner struct {
+	name  string
+	src   string
+	pos   int
+	line  int
+	col   int
+	diags Diagnostics
+}
+
+func newScanner(name, src string) *scanner {
+	return &scanner{name: name, src: src, line: 1, col: 1}
+}
+
+// scanNodes splits src into nodes covering every byte of it. Lines that
+// cannot be read become InvalidNodes and are reported as diagnostics
+// against the file name.
+func scanNodes(name, src string) ([]*Node, Diagnostics) {
+	s := newScanner(name, src)
+	var nodes []*Node
+	for !s.eof() {
+		nodes = append(nodes, s.scanLine())
+	}
+	return nodes, s.diags
+}
+
+func (s *scanner) errorf(at Position, format string, args ...interface{}) {
+	s.diags = append(s.diags, Diagnostic{
+		File:     s.name,
+		Line:     at.Line,
+		Column:   at.Column,
+		Severity: SeverityError,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (s *scanner) position() Position {
+	return Position{Offset: s.pos, Line: s.line, Column: s.col}
+}
+
+func (s *scanner) eof() bool {
+	return s.pos >= len(s.src)
+}
+
+func (s *scanner) peek() byte {
+	if s.eof() {
+		return 0
+	}
+	return s.src[s.pos]
+}
+
+func (s *scanner) next() byte {
+	c := s.src[s.pos]
+	s.pos++
+	if c == '\n' {
+		s.line++
+		s.col = 1
+	} else {
+		s.col++
+	}
+	return c
+}
+
+func (s *scanner) skipBlanks() {
+	for !s.eof() && isBlank(s.peek()) {
+		s.next()
+	}
+}
+
+// skipLine advances past the end of the current line
+func (s *scanner) skipLine() {
+	for !s.eof() {
+		if s.next() == '\n' {
+			return
+		}
+	}
+}
+
+// atLineEnd reports whether only a line terminator remains on this line
+func (s *scanner) atLineEnd() bool {
+	if s.eof() || s.peek() == '\n' {
+		return true
+	}
+	return s.peek() == '\r' && (s.pos+1 == len(s.src) || s.src[s.pos+1] == '\n')
+}
+
+// scanWord reads a run of characters up to whitespace or '='
+func (s *scanner) scanWord() string {
+	start := s.pos
+	for !s.eof() {
+		c := s.peek()
+		if c == '=' || isBlank(c) || c == '\n' || c == '\r' {
+			break
+		}
+		s.next()
+	}
+	return s.src[start:s.pos]
+}
+
+// scanComment reads an inline comment up to the end of the line
+func (s *scanner) scanComment() string {
+	start := s.pos
+	for !s.atLineEnd() {
+		s.next()
+	}
+	return s.src[start:s.pos]
+}
+
+// scanLine reads one logical line, which may span several physical lines
+// when it holds a multiline quoted value
+func (s *scanner) scanLine() *Node {
+	n := &Node{Span: Span{Start: s.position()}}
+	defer func() {
+		s.skipLine()
+		n.Span.End = s.position()
+		n.Text = s.src[n.Span.Start.Offset:n.Span.End.Offset]
+	}()
+
+	s.skipBlanks()
+	if s.atLineEnd() {
+		n.Kind = BlankNode
+		return n
+	}
+	if s.peek() == '#' {
+		n.Kind = CommentNode
+		n.Comment = s.scanComment()
+		return n
+	}
+
+	n.Kind = EntryNode
+	n.KeyPos = s.position()
+	n.Key = s.scanWord()
+	if n.Key == "export" && isBlank(s.peek()) {
+		s.skipBlanks()
+		n.Export = true
+		n.KeyPos = s.position()
+		n.Key = s.scanWord()
+	}
+	s.skipBlanks()
+	if s.peek() != '=' {
+		if n.Key == "" {
+			s.errorf(s.position(), "expected KEY=value")
+		} else {
+			s.errorf(s.position(), "expected '=' after %q", n.Key)
+		}
+		n.Kind = InvalidNode
+		return n
+	}
+	if n.Key == "" {
+		s.errorf(n.KeyPos, "missing key before '='")
+		n.Kind = InvalidNode
+	} else if !IsValidKey(n.Key) {
+		s.errorf(n.KeyPos, "invalid key name %q", n.Key)
+		n.Kind = InvalidNode
+	}
+	s.next()
+	s.skipBlanks()
+
+	q := s.peek()
+	if q == '\'' || q == '"' {
+		at := s.position()
+		if raw, ok := s.scanQuoted(q); ok {
+			n.RawValue, n.Quote = raw, q
+			s.skipBlanks()
+			if !s.atLineEnd() && s.peek() != '#' {
+				s.errorf(s.position(), "unexpected text after quoted value")
+				for !s.atLineEnd() && s.peek() != '#' {
+					s.next()
+				}
+			}
+			if s.peek() == '#' {
+				n.Comment = s.scanComment()
+			}
+			return n
+		}
+		s.errorf(at, "unterminated quoted value")
+	}
+	n.RawValue = s.scanUnquoted()
+	if s.peek() == '#' {
+		n.Comment = s.scanComment()
+	}
+	return n
+}
+
+// scanQuoted reads a quoted value starting at the opening quote. If the
+// closing quote is missing, the scanner is left untouched and ok is false.
+func (s *scanner) scanQuoted(q byte) (raw string, ok bool) {
+	saved := *s
+	s.next()
+	start := s.pos
+	for !s.eof() {
+		c := s.next()
+		switch {
+		case c == q:
+			return s.src[start : s.pos-1], true
+		case c == '\\' && q == '"' && !s.eof():
+			s.next()
+		}
+	}
+	*s = saved
+	return "", false
+}
+
+// scanUnquoted reads the rest of the line, stopping at an inline comment
+// (a '#' preceded by whitespace) and trimming trailing whitespace
+func (s *scanner) scanUnquoted() string {
+	start := s.pos
+	end := s.pos
+	for !s.atLineEnd() {
+		c := s.peek()
+		if c == '#' && isBlank(s.src[s.pos-1]) {
+			break
+		}
+		s.next()
+		if !isBlank(c) {
+			end = s.pos
+		}
+	}
+	return s.src[start:end]
+}
+
+// IsValidKey reports whether key is a portable variable name: a letter or
+// underscore followed by letters, digits or underscores
+func IsValidKey(key string) bool {
+	if key == "" || !isNameStart(key[0]) {
+		return false
+	}
+	for i := 1; i < len(key); i++ {
+		if !isNameChar(key[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
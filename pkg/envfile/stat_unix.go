@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package envfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID returns the inode number of the file described by info
+func fileID(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
@@ -0,0 +1,8 @@
+package envfile
+
+import "os"
+
+// fileID returns 0; os.FileInfo carries no file index on Windows
+func fileID(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,38 @@
+package format
+
+import (
+	"encoding/json"
+
+	"gopkg.in/yaml.v2"
+)
+
+// JSON renders variables as a JSON object of strings
+type JSON struct{}
+
+// Format renders vars as an indented JSON object
+func (f *JSON) Format(vars map[string]string) (string, error) {
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	data, err := json.MarshalIndent(vars, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// YAML renders variables as a YAML mapping of strings
+type YAML struct{}
+
+// Format renders vars as a YAML mapping. Values that YAML would read as
+// another type, such as true or 8080, are quoted.
+func (f *YAML) Format(vars map[string]string) (string, error) {
+	if len(vars) == 0 {
+		return "{}\n", nil
+	}
+	data, err := yaml.Marshal(vars)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
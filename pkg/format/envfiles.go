@@ -0,0 +1,83 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/username/envtool/pkg/envfile"
+)
+
+// Dotenv renders variables as a .env file that envtool, docker-compose
+// and python-dotenv read back unchanged
+type Dotenv struct{}
+
+// Format renders vars as one KEY=value line each, quoted as envtool set
+// would write them
+func (f *Dotenv) Format(vars map[string]string) (string, error) {
+	var b strings.Builder
+	for _, key := range sortedKeys(vars) {
+		raw, quote := envfile.QuoteValue(vars[key])
+		if quote != 0 {
+			raw = string(quote) + raw + string(quote)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", key, raw)
+	}
+	return b.String(), nil
+}
+
+// Docker renders variables for docker run --env-file, which takes every
+// value literally: quotes would become part of the value
+type Docker struct{}
+
+// Format renders vars as one unquoted KEY=value line each. Values with
+// line breaks cannot be written.
+func (f *Docker) Format(vars map[string]string) (string, error) {
+	var b strings.Builder
+	for _, key := range sortedKeys(vars) {
+		value := vars[key]
+		if strings.ContainsAny(value, "\r\n") {
+			return "", fmt.Errorf("%s: docker env files cannot hold line breaks", key)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", key, value)
+	}
+	return b.String(), nil
+}
+
+// Systemd renders variables for the EnvironmentFile= setting of systemd
+// units
+type Systemd struct{}
+
+// Format renders vars as one KEY=value line each. Values are single-quoted
+// unless they need no quotes, or hold a single quote, in which case they
+// are double-quoted with \, ", ` and $ escaped.
+func (f *Systemd) Format(vars map[string]string) (string, error) {
+	var b strings.Builder
+	for _, key := range sortedKeys(vars) {
+		fmt.Fprintf(&b, "%s=%s\n", key, systemdQuote(vars[key]))
+	}
+	return b.String(), nil
+}
+
+// systemdQuote quotes a value as systemd's environment file parser
+// expects it
+func systemdQuote(value string) string {
+	switch {
+	case value == "" || isSafe(value):
+		return value
+	case !strings.Contains(value, "'"):
+		return "'" + value + "'"
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\', '"', '`', '$':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
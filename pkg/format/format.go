@@ -0,0 +1,70 @@
+// Package format renders a set of variables in the formats other tools
+// read them from, such as JSON, a docker --env-file or a Kubernetes
+// ConfigMap, and reads variables back from such formats. Every format
+// lists the variables sorted by name, so that the output only changes when
+// the variables do.
+package format
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Formatter renders variables in one format
+type Formatter interface {
+	Format(vars map[string]string) (string, error)
+}
+
+// Options holds the settings some formats need
+type Options struct {
+	// Name names the Kubernetes object
+	Name string
+	// Namespace, if set, places the Kubernetes object in a namespace
+	Namespace string
+	// Service selects the docker-compose service Parse reads
+	Service string
+}
+
+// Names lists the supported formats
+var Names = []string{"json", "yaml", "dotenv", "docker", "systemd", "k8s-configmap", "k8s-secret"}
+
+// ForName returns the formatter for a format name as passed to envtool
+// export
+func ForName(name string, opts Options) (Formatter, error) {
+	switch strings.ToLower(name) {
+	case "json":
+		return &JSON{}, nil
+	case "yaml", "yml":
+		return &YAML{}, nil
+	case "dotenv", "env":
+		return &Dotenv{}, nil
+	case "docker":
+		return &Docker{}, nil
+	case "systemd":
+		return &Systemd{}, nil
+	case "k8s-configmap", "configmap":
+		return &ConfigMap{Name: opts.Name, Namespace: opts.Namespace}, nil
+	case "k8s-secret", "secret":
+		return &Secret{Name: opts.Name, Namespace: opts.Namespace}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", name)
+}
+
+// sortedKeys returns the names of vars in sorted order
+func sortedKeys(vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isSafe reports whether value is non-empty and made only of characters
+// that no format treats specially
+func isSafe(value string) bool {
+	return value != "" && strings.Trim(value, safeChars) == ""
+}
+
+const safeChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-.,/:@+%"
@@ -0,0 +1,167 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testVars = map[string]string{
+	"PLAIN":   "bar",
+	"EMPTY":   "",
+	"PORT":    "8080",
+	"FLAG":    "true",
+	"QUOTES":  `it's "quoted"`,
+	"SPECIAL": "$HOME \\ `x`\nnext",
+}
+
+func TestForName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected Formatter
+	}{
+		{name: "json", expected: &JSON{}},
+		{name: "YAML", expected: &YAML{}},
+		{name: "dotenv", expected: &Dotenv{}},
+		{name: "docker", expected: &Docker{}},
+		{name: "systemd", expected: &Systemd{}},
+		{name: "k8s-configmap", expected: &ConfigMap{Name: "app", Namespace: "prod"}},
+		{name: "k8s-secret", expected: &Secret{Name: "app", Namespace: "prod"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := ForName(tc.name, Options{Name: "app", Namespace: "prod"})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, f)
+		})
+	}
+	for _, name := range Names {
+		_, err := ForName(name, Options{})
+		assert.NoError(t, err)
+	}
+
+	_, err := ForName("toml", Options{})
+	assert.EqualError(t, err, `unsupported format "toml"`)
+}
+
+func TestFormat(t *testing.T) {
+	testCases := []struct {
+		name      string
+		formatter Formatter
+		expected  string
+	}{
+		{
+			name:      "JSON",
+			formatter: &JSON{},
+			expected: "{\n" +
+				`  "EMPTY": "",` + "\n" +
+				`  "FLAG": "true",` + "\n" +
+				`  "PLAIN": "bar",` + "\n" +
+				`  "PORT": "8080",` + "\n" +
+				`  "QUOTES": "it's \"quoted\"",` + "\n" +
+				`  "SPECIAL": "$HOME \\ ` + "`x`" + `\nnext"` + "\n" +
+				"}\n",
+		},
+		{
+			name:      "YAML",
+			formatter: &YAML{},
+			expected: `EMPTY: ""` + "\n" +
+				`FLAG: "true"` + "\n" +
+				"PLAIN: bar\n" +
+				`PORT: "8080"` + "\n" +
+				`QUOTES: it's "quoted"` + "\n" +
+				"SPECIAL: |-\n" +
+				"  $HOME \\ `x`\n" +
+				"  next\n",
+		},
+		{
+			name:      "Dotenv",
+			formatter: &Dotenv{},
+			expected: "EMPTY=\n" +
+				"FLAG=true\n" +
+				"PLAIN=bar\n" +
+				"PORT=8080\n" +
+				`QUOTES="it's \"quoted\""` + "\n" +
+				`SPECIAL="\$HOME \\ \` + "`x\\`" + `\nnext"` + "\n",
+		},
+		{
+			name:      "Systemd",
+			formatter: &Systemd{},
+			expected: "EMPTY=\n" +
+				"FLAG=true\n" +
+				"PLAIN=bar\n" +
+				"PORT=8080\n" +
+				`QUOTES="it's \"quoted\""` + "\n" +
+				"SPECIAL='$HOME \\ `x`\nnext'\n",
+		},
+		{
+			name:      "ConfigMap",
+			formatter: &ConfigMap{Name: "app"},
+			expected: "apiVersion: v1\n" +
+				"kind: ConfigMap\n" +
+				"metadata:\n" +
+				"  name: app\n" +
+				"data:\n" +
+				`  EMPTY: ""` + "\n" +
+				`  FLAG: "true"` + "\n" +
+				"  PLAIN: bar\n" +
+				`  PORT: "8080"` + "\n" +
+				`  QUOTES: it's "quoted"` + "\n" +
+				"  SPECIAL: |-\n" +
+				"    $HOME \\ `x`\n" +
+				"    next\n",
+		},
+		{
+			name:      "Secret",
+			formatter: &Secret{Name: "app", Namespace: "prod"},
+			expected: "apiVersion: v1\n" +
+				"kind: Secret\n" +
+				"metadata:\n" +
+				"  name: app\n" +
+				"  namespace: prod\n" +
+				"type: Opaque\n" +
+				"data:\n" +
+				`  EMPTY: ""` + "\n" +
+				"  FLAG: dHJ1ZQ==\n" +
+				"  PLAIN: YmFy\n" +
+				"  PORT: ODA4MA==\n" +
+				"  QUOTES: aXQncyAicXVvdGVkIg==\n" +
+				"  SPECIAL: JEhPTUUgXCBgeGAKbmV4dA==\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := tc.formatter.Format(testVars)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, output)
+
+			// Output is the same every time
+			again, err := tc.formatter.Format(testVars)
+			assert.NoError(t, err)
+			assert.Equal(t, output, again)
+		})
+	}
+}
+
+func TestFormat_Errors(t *testing.T) {
+	_, err := (&Docker{}).Format(testVars)
+	assert.EqualError(t, err, "SPECIAL: docker env files cannot hold line breaks")
+
+	output, err := (&Docker{}).Format(map[string]string{"B": `"quoted" $X`, "A": ""})
+	assert.NoError(t, err)
+	assert.Equal(t, "A=\nB=\"quoted\" $X\n", output)
+
+	_, err = (&ConfigMap{}).Format(testVars)
+	assert.EqualError(t, err, "a ConfigMap needs a name")
+	_, err = (&Secret{Name: "app"}).Format(map[string]string{"A B": "1"})
+	assert.EqualError(t, err, "A B: not a valid Secret key")
+
+	output, err = (&JSON{}).Format(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "{}\n", output)
+	output, err = (&YAML{}).Format(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "{}\n", output)
+}
@@ -0,0 +1,81 @@
+package format
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigMap renders variables as a Kubernetes ConfigMap manifest
+type ConfigMap struct {
+	Name      string
+	Namespace string
+}
+
+// Format renders vars as the data of a ConfigMap
+func (f *ConfigMap) Format(vars map[string]string) (string, error) {
+	return manifest("ConfigMap", "", f.Name, f.Namespace, vars, func(value string) string {
+		return value
+	})
+}
+
+// Secret renders variables as an Opaque Kubernetes Secret manifest
+type Secret struct {
+	Name      string
+	Namespace string
+}
+
+// Format renders vars as the base64-encoded data of a Secret
+func (f *Secret) Format(vars map[string]string) (string, error) {
+	return manifest("Secret", "Opaque", f.Name, f.Namespace, vars, func(value string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	})
+}
+
+// k8sObject is the part of a Kubernetes manifest ConfigMap and Secret share
+type k8sObject struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sMetadata       `yaml:"metadata"`
+	Type       string            `yaml:"type,omitempty"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type k8sMetadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// manifest renders a manifest of the given kind with vars as its data,
+// each value passed through encode
+func manifest(kind, typ, name, namespace string, vars map[string]string, encode func(string) string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("a %s needs a name", kind)
+	}
+	obj := k8sObject{
+		APIVersion: "v1",
+		Kind:       kind,
+		Metadata:   k8sMetadata{Name: name, Namespace: namespace},
+		Type:       typ,
+		Data:       make(map[string]string, len(vars)),
+	}
+	for _, key := range sortedKeys(vars) {
+		if !isConfigKey(key) {
+			return "", fmt.Errorf("%s: not a valid %s key", key, kind)
+		}
+		obj.Data[key] = encode(vars[key])
+	}
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// isConfigKey reports whether key is made of the letters, digits, '-', '_'
+// and '.' Kubernetes allows in ConfigMap and Secret keys
+func isConfigKey(key string) bool {
+	return key != "" && strings.Trim(key, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.") == ""
+}
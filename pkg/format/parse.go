@@ -0,0 +1,290 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// InputNames lists the formats Parse reads
+var InputNames = []string{"json", "yaml", "compose", "systemd", "environ"}
+
+// Parse reads variables in the named format from data:
+//
+//	json     an object of strings, numbers and booleans
+//	yaml     a mapping of scalars
+//	compose  the environment of a docker-compose service, chosen by
+//	         opts.Service unless the file has a single service
+//	systemd  a file for the EnvironmentFile= setting of systemd units
+//	environ  NUL-separated KEY=VALUE pairs, as in /proc/PID/environ or
+//	         the output of env -0
+//
+// Null values read as empty strings.
+func Parse(name string, data []byte, opts Options) (map[string]string, error) {
+	switch strings.ToLower(name) {
+	case "json":
+		return parseJSON(data)
+	case "yaml", "yml":
+		return parseYAML(data)
+	case "compose", "docker-compose":
+		return parseCompose(data, opts.Service)
+	case "systemd":
+		return parseSystemd(data)
+	case "environ", "env0":
+		return parseEnviron(data), nil
+	}
+	return nil, fmt.Errorf("unsupported input format %q", name)
+}
+
+// DetectInput guesses the format of data read from path, by its name and
+// then by its content. It returns "" if it cannot tell.
+func DetectInput(path string, data []byte) string {
+	base := strings.ToLower(filepath.Base(path))
+	ext := filepath.Ext(base)
+	switch {
+	case base == "environ" || bytes.IndexByte(data, 0) >= 0:
+		return "environ"
+	case ext == ".json":
+		return "json"
+	case (ext == ".yml" || ext == ".yaml") && strings.Contains(base, "compose"):
+		return "compose"
+	case ext == ".yml" || ext == ".yaml":
+		return "yaml"
+	}
+	return ""
+}
+
+// parseJSON reads a JSON object of scalars
+func parseJSON(data []byte) (map[string]string, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string, len(obj))
+	for key, raw := range obj {
+		raw = bytes.TrimSpace(raw)
+		switch {
+		case len(raw) > 0 && raw[0] == '"':
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			vars[key] = s
+		case string(raw) == "null":
+			vars[key] = ""
+		case len(raw) > 0 && (raw[0] == '{' || raw[0] == '['):
+			return nil, fmt.Errorf("%s: not a string, number or boolean", key)
+		default:
+			// Numbers and booleans keep the text they were written as
+			vars[key] = string(raw)
+		}
+	}
+	return vars, nil
+}
+
+// parseYAML reads a YAML mapping of scalars, which keep the text they were
+// written as
+func parseYAML(data []byte) (map[string]string, error) {
+	vars := map[string]string{}
+	if err := yaml.Unmarshal(data, &vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// parseCompose reads the environment of a docker-compose service, given
+// either as a mapping or as a list of KEY=VALUE strings. Variables without
+// a value, which compose takes from the host, are left out. $$, which
+// compose turns into $, is unescaped; other $ references are kept as they
+// are.
+func parseCompose(data []byte, service string) (map[string]string, error) {
+	var file struct {
+		Services map[string]struct {
+			Environment interface{} `yaml:"environment"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if service == "" {
+		if len(file.Services) != 1 {
+			names := make([]string, 0, len(file.Services))
+			for name := range file.Services {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("choose a service: %s", strings.Join(names, ", "))
+		}
+		for name := range file.Services {
+			service = name
+		}
+	}
+	svc, ok := file.Services[service]
+	if !ok {
+		return nil, fmt.Errorf("no service %q", service)
+	}
+
+	vars := map[string]string{}
+	unescape := func(value string) string {
+		return strings.ReplaceAll(value, "$$", "$")
+	}
+	switch env := svc.Environment.(type) {
+	case nil:
+	case []interface{}:
+		for _, item := range env {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: environment entries must be KEY=VALUE strings", service)
+			}
+			if i := strings.IndexByte(s, '='); i > 0 {
+				vars[s[:i]] = unescape(s[i+1:])
+			}
+		}
+	case map[interface{}]interface{}:
+		for k, v := range env {
+			if v == nil {
+				continue
+			}
+			if _, ok := v.(map[interface{}]interface{}); ok {
+				return nil, fmt.Errorf("%v: not a string, number or boolean", k)
+			}
+			if _, ok := v.([]interface{}); ok {
+				return nil, fmt.Errorf("%v: not a string, number or boolean", k)
+			}
+			vars[fmt.Sprint(k)] = unescape(fmt.Sprint(v))
+		}
+	default:
+		return nil, fmt.Errorf("%s: environment must be a mapping or a list", service)
+	}
+	return vars, nil
+}
+
+// parseSystemd reads a systemd environment file. Lines starting with # or
+// ; are comments. A value may start with a single-quoted part, taken
+// literally, or a double-quoted part, in which \, ", ` and $ may be
+// escaped with a backslash. Elsewhere, a backslash escapes the next
+// character, and a backslash at the end of a line continues it.
+func parseSystemd(data []byte) (map[string]string, error) {
+	vars := map[string]string{}
+	src := strings.ReplaceAll(string(data), "\r\n", "\n")
+	line := 1
+	for len(src) > 0 {
+		// Skip blank lines and comments
+		trimmed := strings.TrimLeft(src, " \t")
+		if trimmed == "" {
+			break
+		}
+		if trimmed[0] == '\n' || trimmed[0] == '#' || trimmed[0] == ';' {
+			end := strings.IndexByte(trimmed, '\n')
+			if end < 0 {
+				break
+			}
+			src = trimmed[end+1:]
+			line++
+			continue
+		}
+
+		eq := strings.IndexByte(trimmed, '=')
+		nl := strings.IndexByte(trimmed, '\n')
+		if eq < 0 || nl >= 0 && nl < eq {
+			return nil, fmt.Errorf("line %d: missing =", line)
+		}
+		key := strings.TrimSpace(trimmed[:eq])
+		var value string
+		var err error
+		value, src, line, err = systemdValue(trimmed[eq+1:], line)
+		if err != nil {
+			return nil, err
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// systemdValue reads the value starting at src, and returns it with the
+// rest of the input and the line number it starts at
+func systemdValue(src string, line int) (string, string, int, error) {
+	var b strings.Builder
+	i := 0
+	for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+		i++
+	}
+
+	if i < len(src) && (src[i] == '\'' || src[i] == '"') {
+		quote := src[i]
+		start := line
+		closed := false
+		for i++; i < len(src); i++ {
+			c := src[i]
+			if c == '\n' {
+				line++
+			}
+			if c == quote {
+				closed = true
+				i++
+				break
+			}
+			if c == '\\' && quote == '"' && i+1 < len(src) {
+				switch next := src[i+1]; next {
+				case '"', '\\', '`', '$':
+					b.WriteByte(next)
+					i++
+					continue
+				case '\n':
+					line++
+					i++
+					continue
+				}
+			}
+			b.WriteByte(c)
+		}
+		if !closed {
+			return "", "", line, fmt.Errorf("line %d: unterminated quote", start)
+		}
+	}
+
+	// The rest of the line is unquoted, with trailing blanks removed
+	trailing := 0
+	for ; i < len(src) && src[i] != '\n'; i++ {
+		c := src[i]
+		if c == '\\' && i+1 < len(src) {
+			i++
+			if src[i] == '\n' {
+				line++
+			} else {
+				b.WriteByte(src[i])
+			}
+			trailing = 0
+			continue
+		}
+		b.WriteByte(c)
+		if c == ' ' || c == '\t' {
+			trailing++
+		} else {
+			trailing = 0
+		}
+	}
+	if i < len(src) {
+		i++
+		line++
+	}
+	value := b.String()
+	return value[:len(value)-trailing], src[i:], line, nil
+}
+
+// parseEnviron reads NUL-separated KEY=VALUE pairs. Entries without a key
+// are left out.
+func parseEnviron(data []byte) map[string]string {
+	vars := map[string]string{}
+	for _, entry := range strings.Split(string(data), "\x00") {
+		if i := strings.IndexByte(entry, '='); i > 0 {
+			vars[entry[:i]] = entry[i+1:]
+		}
+	}
+	return vars
+}
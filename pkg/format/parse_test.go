@@ -0,0 +1,144 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		format   string
+		input    string
+		service  string
+		expected map[string]string
+	}{
+		{
+			name:     "JSON",
+			format:   "json",
+			input:    `{"A": "x y", "PORT": 8080, "RATIO": 1.50, "DEBUG": true, "NONE": null}`,
+			expected: map[string]string{"A": "x y", "PORT": "8080", "RATIO": "1.50", "DEBUG": "true", "NONE": ""},
+		},
+		{
+			name:     "YAML",
+			format:   "yaml",
+			input:    "A: x y\nPORT: 8080\nRATIO: 1.50\nDEBUG: yes\nNONE:\nTEXT: |\n  two\n  lines\n",
+			expected: map[string]string{"A": "x y", "PORT": "8080", "RATIO": "1.50", "DEBUG": "yes", "NONE": "", "TEXT": "two\nlines\n"},
+		},
+		{
+			name:   "Compose mapping",
+			format: "compose",
+			input: "services:\n" +
+				"  web:\n" +
+				"    image: nginx\n" +
+				"    environment:\n" +
+				"      PORT: 8080\n" +
+				"      PRICE: $$5\n" +
+				"      HOME_DIR: ${HOME}\n" +
+				"      FROM_HOST:\n",
+			expected: map[string]string{"PORT": "8080", "PRICE": "$5", "HOME_DIR": "${HOME}"},
+		},
+		{
+			name:   "Compose list",
+			format: "compose",
+			input: "services:\n" +
+				"  web:\n" +
+				"    environment: [A=1]\n" +
+				"  db:\n" +
+				"    environment:\n" +
+				"      - POSTGRES_PASSWORD=p=w\n" +
+				"      - FROM_HOST\n",
+			service:  "db",
+			expected: map[string]string{"POSTGRES_PASSWORD": "p=w"},
+		},
+		{
+			name:   "Systemd",
+			format: "systemd",
+			input: "# comment\n" +
+				"; also a comment\n" +
+				"PLAIN=bar  \n" +
+				"SPACED = a b\n" +
+				"SINGLE='$HOME \\ x'\n" +
+				"DOUBLE=\"it's \\\"quoted\\\" \\$HOME \\n\"\n" +
+				"MULTI='one\n" +
+				"two'\n" +
+				"CONTINUED=one\\\n" +
+				"two\n" +
+				"\n" +
+				"EMPTY=\n",
+			expected: map[string]string{
+				"PLAIN":     "bar",
+				"SPACED":    "a b",
+				"SINGLE":    `$HOME \ x`,
+				"DOUBLE":    `it's "quoted" $HOME \n`,
+				"MULTI":     "one\ntwo",
+				"CONTINUED": "onetwo",
+				"EMPTY":     "",
+			},
+		},
+		{
+			name:     "Environ",
+			format:   "environ",
+			input:    "A=1\x00B=x=y\nz\x00EMPTY=\x00=skipped\x00",
+			expected: map[string]string{"A": "1", "B": "x=y\nz", "EMPTY": ""},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			vars, err := Parse(tc.format, []byte(tc.input), Options{Service: tc.service})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, vars)
+		})
+	}
+}
+
+func TestParse_RoundTrip(t *testing.T) {
+	for _, name := range []string{"json", "yaml", "systemd"} {
+		t.Run(name, func(t *testing.T) {
+			f, err := ForName(name, Options{})
+			assert.NoError(t, err)
+			output, err := f.Format(testVars)
+			assert.NoError(t, err)
+			vars, err := Parse(name, []byte(output), Options{})
+			assert.NoError(t, err)
+			assert.Equal(t, testVars, vars)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	testCases := []struct {
+		format   string
+		input    string
+		expected string
+	}{
+		{format: "json", input: `{"A": {"b": 1}}`, expected: "A: not a string, number or boolean"},
+		{format: "compose", input: "services:\n  a: {}\n  b: {}\n", expected: "choose a service: a, b"},
+		{format: "compose", input: "services:\n  a: {}\n", expected: `no service "web"`},
+		{format: "compose", input: "services:\n  a:\n    environment: {A: [1]}\n", expected: "A: not a string, number or boolean"},
+		{format: "systemd", input: "A=1\nB\n", expected: "line 2: missing ="},
+		{format: "systemd", input: "A='open\n", expected: "line 1: unterminated quote"},
+		{format: "toml", input: "", expected: `unsupported input format "toml"`},
+	}
+
+	for _, tc := range testCases {
+		opts := Options{}
+		if tc.expected == `no service "web"` {
+			opts.Service = "web"
+		}
+		_, err := Parse(tc.format, []byte(tc.input), opts)
+		assert.EqualError(t, err, tc.expected)
+	}
+}
+
+func TestDetectInput(t *testing.T) {
+	assert.Equal(t, "json", DetectInput("config.JSON", []byte("{}")))
+	assert.Equal(t, "compose", DetectInput("docker-compose.yml", nil))
+	assert.Equal(t, "compose", DetectInput("compose.yaml", nil))
+	assert.Equal(t, "yaml", DetectInput("values.yaml", nil))
+	assert.Equal(t, "environ", DetectInput("/proc/1/environ", nil))
+	assert.Equal(t, "environ", DetectInput("-", []byte("A=1\x00")))
+	assert.Equal(t, "", DetectInput("app.env", []byte("A=1\n")))
+}
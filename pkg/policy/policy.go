@@ -0,0 +1,83 @@
+// Package policy decides which variables envtool may set from .env files
+package policy
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// Reserved matches envtool's own variables, which .env files may never set
+const Reserved = "ENVTOOL_*"
+
+// DefaultDeny lists variables that change how programs or the shell
+// itself run, and that a project .env file has no business setting
+var DefaultDeny = []string{
+	"LD_PRELOAD",
+	"LD_LIBRARY_PATH",
+	"BASH_ENV",
+	"PROMPT_COMMAND",
+	"IFS",
+}
+
+// DefaultNamePattern accepts portable variable names
+const DefaultNamePattern = `^[A-Za-z_][A-Za-z0-9_]*$`
+
+// Policy blocks variables by name. A name is blocked when it does not
+// match the name pattern, or when it matches a deny glob and no allow glob.
+// Reserved names are blocked regardless of the allow globs.
+type Policy struct {
+	deny  []string
+	allow []string
+	names *regexp.Regexp
+}
+
+// New builds a policy from deny and allow globs, in path.Match syntax, and
+// a regular expression for valid names. The deny globs add to DefaultDeny;
+// allow globs can lift the defaults. An empty pattern means
+// DefaultNamePattern.
+func New(deny, allow []string, pattern string) (*Policy, error) {
+	p := &Policy{
+		deny:  append(append([]string{}, DefaultDeny...), deny...),
+		allow: append([]string{}, allow...),
+	}
+	for _, glob := range append(append([]string{}, p.deny...), p.allow...) {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid policy glob %q: %w", glob, err)
+		}
+	}
+	if pattern == "" {
+		pattern = DefaultNamePattern
+	}
+	names, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy name pattern: %w", err)
+	}
+	p.names = names
+	return p, nil
+}
+
+// Check returns nil if key may be set, or an error saying why not
+func (p *Policy) Check(key string) error {
+	if ok, _ := path.Match(Reserved, key); ok {
+		return fmt.Errorf("%s is reserved for envtool", key)
+	}
+	if !p.names.MatchString(key) {
+		return fmt.Errorf("%s does not match the name pattern %s", key, p.names)
+	}
+	for _, glob := range p.deny {
+		if ok, _ := path.Match(glob, key); ok && !p.allowed(key) {
+			return fmt.Errorf("%s is denied by policy (%s)", key, glob)
+		}
+	}
+	return nil
+}
+
+func (p *Policy) allowed(key string) bool {
+	for _, glob := range p.allow {
+		if ok, _ := path.Match(glob, key); ok {
+			return true
+		}
+	}
+	return false
+}
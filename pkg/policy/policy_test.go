@@ -0,0 +1,50 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name    string
+		deny    []string
+		allow   []string
+		pattern string
+		key     string
+		err     string
+	}{
+		{name: "Plain key", key: "DATABASE_URL"},
+		{name: "Default deny", key: "LD_PRELOAD", err: "LD_PRELOAD is denied by policy (LD_PRELOAD)"},
+		{name: "Prompt command", key: "PROMPT_COMMAND", err: "PROMPT_COMMAND is denied by policy (PROMPT_COMMAND)"},
+		{name: "Reserved", key: "ENVTOOL_MANAGED_ENV_VARS", err: "ENVTOOL_MANAGED_ENV_VARS is reserved for envtool"},
+		{name: "Reserved cannot be allowed", allow: []string{"ENVTOOL_*"}, key: "ENVTOOL_SAVED_ENV", err: "ENVTOOL_SAVED_ENV is reserved for envtool"},
+		{name: "Configured deny glob", deny: []string{"AWS_*"}, key: "AWS_SECRET_ACCESS_KEY", err: "AWS_SECRET_ACCESS_KEY is denied by policy (AWS_*)"},
+		{name: "Allow lifts a default", allow: []string{"LD_LIBRARY_PATH"}, key: "LD_LIBRARY_PATH"},
+		{name: "Allow list", deny: []string{"*"}, allow: []string{"APP_*"}, key: "APP_PORT"},
+		{name: "Outside allow list", deny: []string{"*"}, allow: []string{"APP_*"}, key: "PATH", err: "PATH is denied by policy (*)"},
+		{name: "Name pattern", pattern: `^[A-Z_][A-Z0-9_]*$`, key: "lower", err: "lower does not match the name pattern ^[A-Z_][A-Z0-9_]*$"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := New(tc.deny, tc.allow, tc.pattern)
+			assert.NoError(t, err)
+			err = p.Check(tc.key)
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New([]string{"["}, nil, "")
+	assert.EqualError(t, err, `invalid policy glob "[": syntax error in pattern`)
+
+	_, err = New(nil, nil, "(")
+	assert.Error(t, err)
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package resolve
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// shellCommand runs command with /bin/sh in its own process group, so that
+// killProcess also stops the processes it started
+func shellCommand(command string) *exec.Cmd {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd
+}
+
+// killProcess kills the process group of a command started by shellCommand
+func killProcess(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
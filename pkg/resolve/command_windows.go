@@ -0,0 +1,13 @@
+package resolve
+
+import "os/exec"
+
+// shellCommand runs command with cmd.exe
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("cmd", "/C", command)
+}
+
+// killProcess kills a command started by shellCommand
+func killProcess(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,106 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// File reads secrets from files: ref+file:///run/secrets/db. Relative
+// paths are taken from the directory of the .env file. Trailing line
+// breaks are removed, as with $(cat file) in a shell.
+type File struct{}
+
+// Resolve reads the file ref points to
+func (File) Resolve(ctx context.Context, ref Ref) (string, error) {
+	path := ref.Target
+	if path == "" {
+		return "", fmt.Errorf("missing file path")
+	}
+	if !filepath.IsAbs(path) && ref.Dir != "" {
+		path = filepath.Join(ref.Dir, path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Env takes secrets from the environment envtool runs in: ref+env://NAME
+type Env struct {
+	// LookupEnv defaults to os.LookupEnv
+	LookupEnv func(string) (string, bool)
+}
+
+// Resolve returns the value of the variable ref names
+func (e Env) Resolve(ctx context.Context, ref Ref) (string, error) {
+	lookupEnv := e.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+	value, ok := lookupEnv(ref.Target)
+	if !ok {
+		return "", fmt.Errorf("%s is not set", ref.Target)
+	}
+	return value, nil
+}
+
+// Command takes secrets from the output of a shell command, run in the
+// directory of the .env file: ref+cmd://pass show db. Trailing line
+// breaks are removed, as with $(command) in a shell.
+type Command struct {
+	// Env is the environment of the command, in the form of os.Environ.
+	// Nil means the environment envtool runs in.
+	Env []string
+}
+
+// Resolve runs the command ref holds and returns its output
+func (c Command) Resolve(ctx context.Context, ref Ref) (string, error) {
+	if strings.TrimSpace(ref.Target) == "" {
+		return "", fmt.Errorf("missing command")
+	}
+	cmd := shellCommand(ref.Target)
+	cmd.Dir = ref.Dir
+	cmd.Env = c.Env
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := run(ctx, cmd); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %v: %s", ref.Target, err, firstLine(msg))
+		}
+		return "", fmt.Errorf("%s: %v", ref.Target, err)
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
+
+// run starts cmd and waits for it to exit, killing it when ctx is done
+func run(ctx context.Context, cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcess(cmd)
+		case <-done:
+		}
+	}()
+	return cmd.Wait()
+}
+
+// firstLine returns s up to its first line break
+func firstLine(s string) string {
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,88 @@
+package resolve
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-resolve-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	path := filepath.Join(tempDir, "db")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("s3cret\n\n"), 0600))
+
+	value, err := File{}.Resolve(context.Background(), Ref{Scheme: "file", Target: path})
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", value)
+
+	value, err = File{}.Resolve(context.Background(), Ref{Scheme: "file", Target: "db", Dir: tempDir})
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", value)
+
+	_, err = File{}.Resolve(context.Background(), Ref{Scheme: "file", Target: "missing", Dir: tempDir})
+	assert.True(t, os.IsNotExist(err))
+	_, err = File{}.Resolve(context.Background(), Ref{Scheme: "file"})
+	assert.Error(t, err)
+}
+
+func TestEnv(t *testing.T) {
+	env := Env{LookupEnv: func(key string) (string, bool) {
+		value, ok := map[string]string{"TOKEN": "abc", "EMPTY": ""}[key]
+		return value, ok
+	}}
+	value, err := env.Resolve(context.Background(), Ref{Scheme: "env", Target: "TOKEN"})
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", value)
+	value, err = env.Resolve(context.Background(), Ref{Scheme: "env", Target: "EMPTY"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", value)
+	_, err = env.Resolve(context.Background(), Ref{Scheme: "env", Target: "MISSING"})
+	assert.EqualError(t, err, "MISSING is not set")
+}
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	tempDir, err := ioutil.TempDir("", "envtool-resolve-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	tempDir, err = filepath.EvalSymlinks(tempDir)
+	assert.NoError(t, err)
+
+	value, err := Command{}.Resolve(context.Background(), Ref{Scheme: "cmd", Target: "printf 'a b\\n'; pwd", Dir: tempDir})
+	assert.NoError(t, err)
+	assert.Equal(t, "a b\n"+tempDir, value)
+
+	_, err = Command{}.Resolve(context.Background(), Ref{Scheme: "cmd", Target: "echo nope >&2; exit 3"})
+	assert.EqualError(t, err, "echo nope >&2; exit 3: exit status 3: nope")
+	_, err = Command{}.Resolve(context.Background(), Ref{Scheme: "cmd", Target: " "})
+	assert.Error(t, err)
+
+	// Env replaces the environment the command runs in
+	os.Setenv("ENVTOOL_RESOLVE_TEST", "leaked")
+	defer os.Unsetenv("ENVTOOL_RESOLVE_TEST")
+	value, err = Command{Env: []string{"ONLY=1"}}.Resolve(context.Background(), Ref{Scheme: "cmd", Target: "echo \"$ONLY${ENVTOOL_RESOLVE_TEST}\""})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", value)
+
+	// A timeout also stops the processes the command started
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = Command{}.Resolve(ctx, Ref{Scheme: "cmd", Target: "sleep 10 | cat"})
+	assert.Error(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
@@ -0,0 +1,152 @@
+// Package resolve replaces secret references in .env values with the
+// secrets they point to. A reference is a value of the form
+// ref+<scheme>://<target>, such as ref+file:///run/secrets/db or
+// ref+vault://secret/data/app#password. Each scheme is handled by a
+// Provider, with its own timeout and cache lifetime.
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Prefix starts every reference
+const Prefix = "ref+"
+
+// Ref is a parsed reference
+type Ref struct {
+	// Scheme selects the provider, such as "file" or "vault"
+	Scheme string
+	// Target is everything after "ref+<scheme>://"
+	Target string
+	// File is the .env file holding the reference
+	File string
+	// Dir is the directory of File. Providers resolve relative paths
+	// against it.
+	Dir string
+}
+
+func (r Ref) String() string {
+	return Prefix + r.Scheme + "://" + r.Target
+}
+
+// Parse splits a reference into its parts. It reports false if value is
+// not a reference.
+func Parse(value string) (Ref, bool) {
+	if !strings.HasPrefix(value, Prefix) {
+		return Ref{}, false
+	}
+	rest := value[len(Prefix):]
+	i := strings.Index(rest, "://")
+	if i <= 0 {
+		return Ref{}, false
+	}
+	for _, c := range rest[:i] {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9') {
+			return Ref{}, false
+		}
+	}
+	return Ref{Scheme: rest[:i], Target: rest[i+len("://"):]}, true
+}
+
+// IsReference reports whether value is a reference
+func IsReference(value string) bool {
+	_, ok := Parse(value)
+	return ok
+}
+
+// Provider fetches the secret a reference points to
+type Provider interface {
+	Resolve(ctx context.Context, ref Ref) (string, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface
+type ProviderFunc func(ctx context.Context, ref Ref) (string, error)
+
+// Resolve calls f(ctx, ref)
+func (f ProviderFunc) Resolve(ctx context.Context, ref Ref) (string, error) {
+	return f(ctx, ref)
+}
+
+// Options controls how a provider is called
+type Options struct {
+	// Timeout bounds each call to the provider; 0 means no limit
+	Timeout time.Duration
+	// TTL is how long a resolved value may be taken from the cache
+	// instead of calling the provider again; 0 disables caching
+	TTL time.Duration
+}
+
+// Cache remembers the value each variable was resolved to, so that it can
+// be reused by later runs
+type Cache interface {
+	// Get returns the value key was resolved to, if it was resolved from
+	// the same reference no longer than maxAge ago
+	Get(key, ref string, maxAge time.Duration) (string, bool)
+	// Put records that key was resolved from ref to value
+	Put(key, ref, value string)
+}
+
+type registration struct {
+	provider Provider
+	opts     Options
+}
+
+// Resolver dispatches references to the provider of their scheme
+type Resolver struct {
+	// Cache, if set, is consulted for providers with a TTL
+	Cache     Cache
+	providers map[string]registration
+}
+
+// NewResolver returns a resolver without providers
+func NewResolver() *Resolver {
+	return &Resolver{providers: map[string]registration{}}
+}
+
+// Register makes p handle the references with the given scheme
+func (r *Resolver) Register(scheme string, p Provider, opts Options) {
+	r.providers[scheme] = registration{provider: p, opts: opts}
+}
+
+// Resolve returns the secret value points to if it is a reference, and
+// value itself otherwise. key is the variable value is assigned to, and
+// file the .env file assigning it.
+func (r *Resolver) Resolve(ctx context.Context, key, value, file string) (string, error) {
+	ref, ok := Parse(value)
+	if !ok {
+		return value, nil
+	}
+	ref.File, ref.Dir = file, filepath.Dir(file)
+	reg, ok := r.providers[ref.Scheme]
+	if !ok {
+		return "", fmt.Errorf("unknown reference provider %q", ref.Scheme)
+	}
+
+	caching := r.Cache != nil && reg.opts.TTL > 0
+	if caching {
+		if cached, ok := r.Cache.Get(key, value, reg.opts.TTL); ok {
+			return cached, nil
+		}
+	}
+
+	if reg.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, reg.opts.Timeout)
+		defer cancel()
+	}
+	resolved, err := reg.provider.Resolve(ctx, ref)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("%s: timed out after %s", ref.Scheme, reg.opts.Timeout)
+		}
+		return "", fmt.Errorf("%s: %w", ref.Scheme, err)
+	}
+	if caching {
+		r.Cache.Put(key, value, resolved)
+	}
+	return resolved, nil
+}
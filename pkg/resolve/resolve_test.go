@@ -0,0 +1,107 @@
+package resolve
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		value string
+		ref   Ref
+		ok    bool
+	}{
+		{"ref+file:///run/secrets/db", Ref{Scheme: "file", Target: "/run/secrets/db"}, true},
+		{"ref+cmd://pass show db", Ref{Scheme: "cmd", Target: "pass show db"}, true},
+		{"ref+vault://secret/data/app#password", Ref{Scheme: "vault", Target: "secret/data/app#password"}, true},
+		{"ref+env://HOME", Ref{Scheme: "env", Target: "HOME"}, true},
+		{"ref+env://", Ref{Scheme: "env", Target: ""}, true},
+		{"plain", Ref{}, false},
+		{"ref+://x", Ref{}, false},
+		{"ref+Env://HOME", Ref{}, false},
+		{"ref+env", Ref{}, false},
+		{"xref+env://HOME", Ref{}, false},
+	}
+	for _, tc := range testCases {
+		ref, ok := Parse(tc.value)
+		assert.Equal(t, tc.ok, ok, tc.value)
+		assert.Equal(t, tc.ref, ref, tc.value)
+		assert.Equal(t, tc.ok, IsReference(tc.value), tc.value)
+		if ok {
+			assert.Equal(t, tc.value, ref.String())
+		}
+	}
+}
+
+// mapCache is a Cache that ignores maxAge unless expired is set
+type mapCache struct {
+	values  map[string]string
+	expired bool
+}
+
+func (c *mapCache) Get(key, ref string, maxAge time.Duration) (string, bool) {
+	value, ok := c.values[key+" "+ref]
+	return value, ok && !c.expired
+}
+
+func (c *mapCache) Put(key, ref, value string) {
+	c.values[key+" "+ref] = value
+}
+
+func TestResolver(t *testing.T) {
+	calls := 0
+	r := NewResolver()
+	r.Register("test", ProviderFunc(func(ctx context.Context, ref Ref) (string, error) {
+		calls++
+		if ref.Target == "fail" {
+			return "", errors.New("boom")
+		}
+		return ref.Dir + ":" + ref.Target, nil
+	}), Options{TTL: time.Minute})
+	r.Register("slow", ProviderFunc(func(ctx context.Context, ref Ref) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}), Options{Timeout: 10 * time.Millisecond})
+
+	value, err := r.Resolve(context.Background(), "A", "plain", "/dir/.env")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain", value)
+
+	value, err = r.Resolve(context.Background(), "A", "ref+test://x", "/dir/.env")
+	assert.NoError(t, err)
+	assert.Equal(t, "/dir:x", value)
+
+	_, err = r.Resolve(context.Background(), "A", "ref+test://fail", "/dir/.env")
+	assert.EqualError(t, err, "test: boom")
+	_, err = r.Resolve(context.Background(), "A", "ref+nope://x", "/dir/.env")
+	assert.EqualError(t, err, `unknown reference provider "nope"`)
+	_, err = r.Resolve(context.Background(), "A", "ref+slow://x", "/dir/.env")
+	assert.EqualError(t, err, "slow: timed out after 10ms")
+
+	// Cached values are reused while they are fresh
+	cache := &mapCache{values: map[string]string{}}
+	r.Cache = cache
+	calls = 0
+	for i := 0; i < 2; i++ {
+		value, err = r.Resolve(context.Background(), "A", "ref+test://x", "/dir/.env")
+		assert.NoError(t, err)
+		assert.Equal(t, "/dir:x", value)
+	}
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, map[string]string{"A ref+test://x": "/dir:x"}, cache.values)
+
+	cache.expired = true
+	_, err = r.Resolve(context.Background(), "A", "ref+test://x", "/dir/.env")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// Failures are not cached
+	cache.expired = false
+	_, err = r.Resolve(context.Background(), "B", "ref+test://fail", "/dir/.env")
+	assert.Error(t, err)
+	assert.NotContains(t, cache.values, "B ref+test://fail")
+}
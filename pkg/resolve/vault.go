@@ -0,0 +1,117 @@
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Vault reads secrets from HashiCorp Vault's KV secrets engine over its
+// HTTP API: ref+vault://secret/data/app#password reads the password field
+// of the secret at secret/data/app. Both KV version 1 and version 2
+// responses are understood. Secrets read during a run are kept in memory,
+// so that several fields of one secret take a single request.
+type Vault struct {
+	// Addr is the server's base URL, such as https://vault.example.com:8200
+	Addr string
+	// Token is sent in the X-Vault-Token header
+	Token string
+	// Namespace, if set, is sent in the X-Vault-Namespace header
+	Namespace string
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+
+	secrets map[string]map[string]interface{}
+}
+
+// Resolve reads the field of the secret ref points to
+func (v *Vault) Resolve(ctx context.Context, ref Ref) (string, error) {
+	i := strings.LastIndexByte(ref.Target, '#')
+	if i < 0 || i == len(ref.Target)-1 {
+		return "", fmt.Errorf("%s: missing #field", ref.Target)
+	}
+	path, field := strings.Trim(ref.Target[:i], "/"), ref.Target[i+1:]
+
+	data, ok := v.secrets[path]
+	if !ok {
+		var err error
+		if data, err = v.read(ctx, path); err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		if v.secrets == nil {
+			v.secrets = map[string]map[string]interface{}{}
+		}
+		v.secrets[path] = data
+	}
+
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("%s: no field %q", path, field)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// read fetches the data of the secret at path
+func (v *Vault) read(ctx context.Context, path string) (map[string]interface{}, error) {
+	if v.Addr == "" {
+		return nil, fmt.Errorf("no Vault address; set VAULT_ADDR")
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(v.Addr, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if v.Token != "" {
+		req.Header.Set("X-Vault-Token", v.Token)
+	}
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var secret struct {
+		Data   map[string]interface{} `json:"data"`
+		Errors []string               `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("malformed response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(secret.Errors) > 0 {
+			return nil, fmt.Errorf("%s: %s", resp.Status, strings.Join(secret.Errors, "; "))
+		}
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+
+	// KV version 2 wraps the secret in data.data, next to data.metadata
+	if inner, ok := secret.Data["data"].(map[string]interface{}); ok {
+		if _, ok := secret.Data["metadata"]; ok {
+			return inner, nil
+		}
+	}
+	return secret.Data, nil
+}
@@ -0,0 +1,68 @@
+package resolve
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVault(t *testing.T) {
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		assert.Equal(t, "team", r.Header.Get("X-Vault-Namespace"))
+		switch r.URL.Path {
+		case "/v1/secret/data/app":
+			w.Write([]byte(`{"data":{"data":{"password":"hunter2","port":5432},"metadata":{"version":3}}}`))
+		case "/v1/kv/app":
+			w.Write([]byte(`{"data":{"password":"v1pass","data":"not nested"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	v := &Vault{Addr: server.URL + "/", Token: "root", Namespace: "team"}
+	resolve := func(target string) (string, error) {
+		return v.Resolve(context.Background(), Ref{Scheme: "vault", Target: target})
+	}
+
+	value, err := resolve("secret/data/app#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", value)
+	value, err = resolve("secret/data/app#port")
+	assert.NoError(t, err)
+	assert.Equal(t, "5432", value)
+	assert.Equal(t, 1, requests["/v1/secret/data/app"])
+
+	value, err = resolve("/kv/app#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1pass", value)
+	value, err = resolve("kv/app#data")
+	assert.NoError(t, err)
+	assert.Equal(t, "not nested", value)
+
+	_, err = resolve("secret/data/app#missing")
+	assert.EqualError(t, err, `secret/data/app: no field "missing"`)
+	_, err = resolve("secret/data/app")
+	assert.EqualError(t, err, "secret/data/app: missing #field")
+	_, err = resolve("secret/data/other#x")
+	assert.EqualError(t, err, "secret/data/other: 404 Not Found")
+
+	v = &Vault{Addr: server.URL, Token: "wrong", Namespace: "team"}
+	_, err = v.Resolve(context.Background(), Ref{Scheme: "vault", Target: "secret/data/app#password"})
+	assert.EqualError(t, err, "secret/data/app: 403 Forbidden: permission denied")
+
+	v = &Vault{}
+	_, err = v.Resolve(context.Background(), Ref{Scheme: "vault", Target: "secret/data/app#password"})
+	assert.EqualError(t, err, "secret/data/app: no Vault address; set VAULT_ADDR")
+}
@@ -0,0 +1,303 @@
+// Package schema describes the variables a project's .env files are
+// expected to set, in a YAML file next to them, usually .env.schema:
+//
+//	DATABASE_URL:
+//	  type: url
+//	  required: true
+//	  secret: true
+//	  description: Postgres connection string
+//	LOG_LEVEL:
+//	  type: enum
+//	  values: [debug, info, warn, error]
+//	  default: info
+//
+// A schema is used to check the merged variables for missing, extra and
+// invalid values, and to fill in defaults.
+package schema
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/username/envtool/pkg/envfile"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultName is the schema file looked for in the current directory
+const DefaultName = ".env.schema"
+
+// Type is the kind of value a variable holds
+type Type string
+
+const (
+	// String accepts any value; it is the default type
+	String Type = "string"
+	// Int accepts decimal integers
+	Int Type = "int"
+	// Bool accepts true, false, 1, 0, yes, no, on and off
+	Bool Type = "bool"
+	// URL accepts absolute URLs with a host
+	URL Type = "url"
+	// Port accepts port numbers from 1 to 65535
+	Port Type = "port"
+	// Enum accepts the listed values
+	Enum Type = "enum"
+	// Duration accepts Go durations such as 1h30m
+	Duration Type = "duration"
+	// Regex accepts values entirely matched by the pattern
+	Regex Type = "regex"
+)
+
+// Variable is the description of one variable
+type Variable struct {
+	// Name is the variable's name
+	Name string `yaml:"-"`
+	// Line is where the variable is described in the schema file
+	Line int `yaml:"-"`
+
+	Type        Type    `yaml:"type"`
+	Required    bool    `yaml:"required"`
+	Default     *string `yaml:"default"`
+	Secret      bool    `yaml:"secret"`
+	Description string  `yaml:"description"`
+	// Values lists the values of an enum
+	Values []string `yaml:"values"`
+	// Pattern is the regular expression of a regex
+	Pattern string `yaml:"pattern"`
+
+	pattern *regexp.Regexp
+}
+
+// Schema is a parsed schema file
+type Schema struct {
+	// Path is the schema file, used in diagnostics
+	Path string
+	// Variables lists the described variables sorted by name
+	Variables []*Variable
+}
+
+// Load reads and parses the schema file at path
+func Load(path string) (*Schema, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, data)
+}
+
+// Parse parses a schema. Unknown types, enums without values, invalid
+// patterns and defaults of the wrong type are errors.
+func Parse(path string, data []byte) (*Schema, error) {
+	var vars map[string]*Variable
+	if err := yaml.UnmarshalStrict(data, &vars); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	lines := keyLines(data)
+
+	s := &Schema{Path: path}
+	for name, v := range vars {
+		if v == nil {
+			v = &Variable{}
+		}
+		v.Name, v.Line = name, lines[name]
+		if err := v.compile(); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %w", path, v.Line, name, err)
+		}
+		s.Variables = append(s.Variables, v)
+	}
+	sort.Slice(s.Variables, func(i, j int) bool {
+		return s.Variables[i].Name < s.Variables[j].Name
+	})
+	return s, nil
+}
+
+// keyLines returns the line of each top-level key in a YAML mapping
+func keyLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if line == "" || line[0] == ' ' || line[0] == '\t' || line[0] == '#' {
+			continue
+		}
+		if i := strings.IndexByte(line, ':'); i > 0 {
+			key := strings.Trim(strings.TrimSpace(line[:i]), `"'`)
+			if _, ok := lines[key]; !ok {
+				lines[key] = n
+			}
+		}
+	}
+	return lines
+}
+
+// compile checks the description and prepares its pattern
+func (v *Variable) compile() error {
+	switch v.Type {
+	case "":
+		v.Type = String
+	case String, Int, Bool, URL, Port, Duration:
+	case Enum:
+		if len(v.Values) == 0 {
+			return fmt.Errorf("enum without values")
+		}
+	case Regex:
+		if v.Pattern == "" {
+			return fmt.Errorf("regex without pattern")
+		}
+		re, err := regexp.Compile(`^(?:` + v.Pattern + `)$`)
+		if err != nil {
+			return err
+		}
+		v.pattern = re
+	default:
+		return fmt.Errorf("unknown type %q", v.Type)
+	}
+	if v.Default != nil {
+		if err := v.Validate(*v.Default); err != nil {
+			return fmt.Errorf("default: %w", err)
+		}
+	}
+	return nil
+}
+
+// Lookup returns the description of the named variable, or nil
+func (s *Schema) Lookup(name string) *Variable {
+	i := sort.Search(len(s.Variables), func(i int) bool {
+		return s.Variables[i].Name >= name
+	})
+	if i < len(s.Variables) && s.Variables[i].Name == name {
+		return s.Variables[i]
+	}
+	return nil
+}
+
+// Validate reports whether value is of the variable's type. Values of
+// secret variables are left out of the error.
+func (v *Variable) Validate(value string) error {
+	if v.valid(value) {
+		return nil
+	}
+	what := fmt.Sprintf("%q", value)
+	if v.Secret {
+		what = "value"
+	}
+	switch v.Type {
+	case Enum:
+		return fmt.Errorf("%s is not one of %s", what, strings.Join(v.Values, ", "))
+	case Regex:
+		return fmt.Errorf("%s does not match %s", what, v.Pattern)
+	}
+	return fmt.Errorf("%s is not a valid %s", what, v.Type)
+}
+
+func (v *Variable) valid(value string) bool {
+	switch v.Type {
+	case Int:
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	case Bool:
+		switch strings.ToLower(value) {
+		case "true", "false", "1", "0", "yes", "no", "on", "off":
+			return true
+		}
+		return false
+	case URL:
+		u, err := url.Parse(value)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	case Port:
+		n, err := strconv.Atoi(value)
+		return err == nil && n >= 1 && n <= 65535
+	case Enum:
+		for _, allowed := range v.Values {
+			if value == allowed {
+				return true
+			}
+		}
+		return false
+	case Duration:
+		_, err := time.ParseDuration(value)
+		return err == nil
+	case Regex:
+		return v.pattern.MatchString(value)
+	}
+	return true
+}
+
+// Check validates merged variables against the schema. Required variables
+// that are not set and have no default are errors reported at the schema,
+// invalid values are errors reported where they are set, and variables the
+// schema does not describe are warnings. skip reports values that cannot
+// be checked, such as secret references, and may be nil.
+func (s *Schema) Check(merged *envfile.Merged, skip func(value string) bool) envfile.Diagnostics {
+	var diags envfile.Diagnostics
+	for _, v := range s.Variables {
+		value, ok := merged.Values[v.Name]
+		if !ok {
+			if v.Required && v.Default == nil {
+				diags = append(diags, envfile.Diagnostic{
+					File: s.Path, Line: v.Line, Column: 1,
+					Severity: envfile.SeverityError,
+					Message:  fmt.Sprintf("%s is required but not set", v.Name),
+				})
+			}
+			continue
+		}
+		if skip != nil && skip(value) {
+			continue
+		}
+		if err := v.Validate(value); err != nil {
+			origin := merged.Origins[v.Name]
+			diags = append(diags, envfile.Diagnostic{
+				File: origin.File, Line: origin.Line, Column: 1,
+				Severity: envfile.SeverityError,
+				Message:  fmt.Sprintf("%s: %v", v.Name, err),
+			})
+		}
+	}
+
+	for name, origin := range merged.Origins {
+		if s.Lookup(name) == nil {
+			diags = append(diags, envfile.Diagnostic{
+				File: origin.File, Line: origin.Line, Column: 1,
+				Severity: envfile.SeverityWarning,
+				Message:  fmt.Sprintf("%s is not in the schema %s", name, s.Path),
+			})
+		}
+	}
+
+	fileIndex := make(map[string]int, len(merged.Files)+1)
+	for i, file := range merged.Files {
+		fileIndex[file] = i + 1
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i], diags[j]
+		if a.File != b.File {
+			return fileIndex[a.File] < fileIndex[b.File]
+		}
+		return a.Line < b.Line
+	})
+	return diags
+}
+
+// ApplyDefaults sets the variables merged lacks to their defaults, with
+// the schema as their origin. It returns the names it set.
+func (s *Schema) ApplyDefaults(merged *envfile.Merged) []string {
+	var set []string
+	for _, v := range s.Variables {
+		if _, ok := merged.Values[v.Name]; ok || v.Default == nil {
+			continue
+		}
+		merged.Values[v.Name] = *v.Default
+		merged.Origins[v.Name] = envfile.Origin{File: s.Path, Line: v.Line}
+		set = append(set, v.Name)
+	}
+	return set
+}
@@ -0,0 +1,159 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/username/envtool/pkg/envfile"
+)
+
+const testSchema = `# Service settings
+DATABASE_URL:
+  type: url
+  required: true
+  secret: true
+  description: Postgres connection string
+PORT:
+  type: port
+  default: 8080
+LOG_LEVEL:
+  type: enum
+  values: [debug, info, warn]
+  default: info
+"DEBUG":
+  type: bool
+TIMEOUT: {type: duration, required: true}
+REPLICAS: {type: int}
+NAME:
+  type: regex
+  pattern: '[a-z]+'
+NOTES:
+`
+
+func TestParse(t *testing.T) {
+	s, err := Parse(".env.schema", []byte(testSchema))
+	assert.NoError(t, err)
+
+	var names []string
+	for _, v := range s.Variables {
+		names = append(names, v.Name)
+	}
+	assert.Equal(t, []string{"DATABASE_URL", "DEBUG", "LOG_LEVEL", "NAME", "NOTES", "PORT", "REPLICAS", "TIMEOUT"}, names)
+
+	db := s.Lookup("DATABASE_URL")
+	assert.Equal(t, 2, db.Line)
+	assert.Equal(t, URL, db.Type)
+	assert.True(t, db.Required)
+	assert.True(t, db.Secret)
+	assert.Equal(t, "Postgres connection string", db.Description)
+	assert.Equal(t, "8080", *s.Lookup("PORT").Default)
+	assert.Equal(t, 14, s.Lookup("DEBUG").Line)
+	assert.Equal(t, String, s.Lookup("NOTES").Type)
+	assert.Nil(t, s.Lookup("MISSING"))
+}
+
+func TestParse_Errors(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{input: "A:\n  type: float\n", expected: `.env.schema:1: A: unknown type "float"`},
+		{input: "A:\n  type: enum\n", expected: ".env.schema:1: A: enum without values"},
+		{input: "A:\n  type: regex\n", expected: ".env.schema:1: A: regex without pattern"},
+		{input: "A:\n  type: regex\n  pattern: '('\n", expected: ".env.schema:1: A: error parsing regexp: missing closing ): `^(?:()$`"},
+		{input: "X: {}\nA:\n  type: port\n  default: 0\n", expected: `.env.schema:2: A: default: "0" is not a valid port`},
+	}
+	for _, tc := range testCases {
+		_, err := Parse(".env.schema", []byte(tc.input))
+		assert.EqualError(t, err, tc.expected)
+	}
+
+	_, err := Parse(".env.schema", []byte("A:\n  typ: int\n"))
+	assert.Error(t, err)
+}
+
+func TestVariable_Validate(t *testing.T) {
+	s, err := Parse(".env.schema", []byte(testSchema))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{name: "DATABASE_URL", value: "postgres://db:5432/app"},
+		{name: "DATABASE_URL", value: "localhost", err: "value is not a valid url"},
+		{name: "PORT", value: "65535"},
+		{name: "PORT", value: "65536", err: `"65536" is not a valid port`},
+		{name: "LOG_LEVEL", value: "warn"},
+		{name: "LOG_LEVEL", value: "WARN", err: `"WARN" is not one of debug, info, warn`},
+		{name: "DEBUG", value: "Yes"},
+		{name: "DEBUG", value: "maybe", err: `"maybe" is not a valid bool`},
+		{name: "TIMEOUT", value: "1m30s"},
+		{name: "TIMEOUT", value: "90", err: `"90" is not a valid duration`},
+		{name: "REPLICAS", value: "-3"},
+		{name: "REPLICAS", value: "3.5", err: `"3.5" is not a valid int`},
+		{name: "NAME", value: "web"},
+		{name: "NAME", value: "web1", err: `"web1" does not match [a-z]+`},
+		{name: "NOTES", value: "anything at all"},
+	}
+	for _, tc := range testCases {
+		err := s.Lookup(tc.name).Validate(tc.value)
+		if tc.err == "" {
+			assert.NoError(t, err, tc.name)
+		} else {
+			assert.EqualError(t, err, tc.err, tc.name)
+		}
+	}
+}
+
+func TestSchema_Check(t *testing.T) {
+	s, err := Parse(".env.schema", []byte(testSchema))
+	assert.NoError(t, err)
+
+	merged := &envfile.Merged{
+		Values: map[string]string{
+			"DATABASE_URL": "ref+cmd://pass show db",
+			"PORT":         "http",
+			"LOG_LEVEL":    "info",
+			"EXTRA":        "1",
+		},
+		Origins: map[string]envfile.Origin{
+			"DATABASE_URL": {File: ".env", Line: 1},
+			"PORT":         {File: ".env.local", Line: 2},
+			"LOG_LEVEL":    {File: ".env", Line: 3},
+			"EXTRA":        {File: ".env", Line: 4},
+		},
+		Files: []string{".env", ".env.local"},
+	}
+	skip := func(value string) bool { return value == "ref+cmd://pass show db" }
+
+	var messages []string
+	for _, d := range s.Check(merged, skip) {
+		messages = append(messages, d.String())
+	}
+	assert.Equal(t, []string{
+		".env.schema:16:1: error: TIMEOUT is required but not set",
+		".env:4:1: warning: EXTRA is not in the schema .env.schema",
+		`.env.local:2:1: error: PORT: "http" is not a valid port`,
+	}, messages)
+
+	// Without skipping references, the reference is not a URL
+	diags := s.Check(merged, nil)
+	if assert.Len(t, diags, 4) {
+		assert.Equal(t, ".env:1:1: error: DATABASE_URL: value is not a valid url", diags[1].String())
+	}
+}
+
+func TestSchema_ApplyDefaults(t *testing.T) {
+	s, err := Parse(".env.schema", []byte(testSchema))
+	assert.NoError(t, err)
+
+	merged := &envfile.Merged{
+		Values:  map[string]string{"PORT": "9000"},
+		Origins: map[string]envfile.Origin{"PORT": {File: ".env", Line: 1}},
+	}
+	assert.Equal(t, []string{"LOG_LEVEL"}, s.ApplyDefaults(merged))
+	assert.Equal(t, map[string]string{"PORT": "9000", "LOG_LEVEL": "info"}, merged.Values)
+	assert.Equal(t, envfile.Origin{File: ".env.schema", Line: 10}, merged.Origins["LOG_LEVEL"])
+}
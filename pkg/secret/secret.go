@@ -0,0 +1,283 @@
+// Package secret encrypts .env files and single values with age X25519
+// recipients, so that secrets can be committed and still be loaded by
+// envtool. A whole file is stored ASCII-armored under its plain name with
+// an .enc suffix; a single value is stored inline as ENC[age:<base64>].
+// Decryption only ever happens in memory.
+package secret
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/username/envtool/pkg/envfile"
+)
+
+// Suffix ends the name of files that are encrypted as a whole
+const Suffix = ".enc"
+
+// Encrypted values are written as valuePrefix + base64 + valueSuffix
+const (
+	valuePrefix = "ENC[age:"
+	valueSuffix = "]"
+)
+
+// DefaultIdentityPath returns $XDG_CONFIG_HOME/envtool/identity.txt,
+// falling back to ~/.config/envtool/identity.txt
+func DefaultIdentityPath() (string, error) {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil || home == "" {
+			return "", fmt.Errorf("cannot locate the age identity: HOME is not set")
+		}
+		configDir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configDir, "envtool", "identity.txt"), nil
+}
+
+// IsEncryptedFile reports whether path names a file encrypted as a whole
+func IsEncryptedFile(path string) bool {
+	return strings.HasSuffix(path, Suffix)
+}
+
+// IsEncryptedValue reports whether value has the ENC[age:...] form
+func IsEncryptedValue(value string) bool {
+	return strings.HasPrefix(value, valuePrefix) && strings.HasSuffix(value, valueSuffix) &&
+		len(value) > len(valuePrefix)+len(valueSuffix)
+}
+
+// Encrypt encrypts plaintext to recipients and returns it ASCII-armored
+func Encrypt(plaintext []byte, recipients []age.Recipient) ([]byte, error) {
+	var buf bytes.Buffer
+	a := armor.NewWriter(&buf)
+	if err := encrypt(a, plaintext, recipients); err != nil {
+		return nil, err
+	}
+	if err := a.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decrypt decrypts data written by Encrypt. Binary age files are accepted
+// too.
+func Decrypt(data []byte, identities []age.Identity) ([]byte, error) {
+	var src io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+	return decrypt(src, identities)
+}
+
+// EncryptValue encrypts a single value to recipients and returns it in the
+// ENC[age:...] form
+func EncryptValue(value string, recipients []age.Recipient) (string, error) {
+	var buf bytes.Buffer
+	if err := encrypt(&buf, []byte(value), recipients); err != nil {
+		return "", err
+	}
+	return valuePrefix + base64.StdEncoding.EncodeToString(buf.Bytes()) + valueSuffix, nil
+}
+
+// DecryptValue decrypts a value returned by EncryptValue
+func DecryptValue(value string, identities []age.Identity) (string, error) {
+	if !IsEncryptedValue(value) {
+		return "", fmt.Errorf("value is not of the form %s...%s", valuePrefix, valueSuffix)
+	}
+	data, err := base64.StdEncoding.DecodeString(value[len(valuePrefix) : len(value)-len(valueSuffix)])
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	plaintext, err := decrypt(bytes.NewReader(data), identities)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func encrypt(dst io.Writer, plaintext []byte, recipients []age.Recipient) error {
+	if len(recipients) == 0 {
+		return fmt.Errorf("no recipients to encrypt to")
+	}
+	w, err := age.Encrypt(dst, recipients...)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func decrypt(src io.Reader, identities []age.Identity) ([]byte, error) {
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no identities to decrypt with")
+	}
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// ReadIdentities reads an age identity file, as written by age-keygen or
+// GenerateIdentity
+func ReadIdentities(path string) ([]age.Identity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return identities, nil
+}
+
+// GenerateIdentity creates a new X25519 identity and writes it to path,
+// which must not exist yet. The file is only readable by its owner.
+func GenerateIdentity(path string) (*age.X25519Identity, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "# public key: %s\n%s\n", identity.Recipient(), identity)
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// ParseRecipients parses age1... public keys
+func ParseRecipients(keys []string) ([]age.Recipient, error) {
+	recipients := make([]age.Recipient, 0, len(keys))
+	for _, key := range keys {
+		r, err := age.ParseX25519Recipient(strings.TrimSpace(key))
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+// IdentityRecipients returns the recipients of the X25519 identities, so
+// that data can be encrypted to oneself
+func IdentityRecipients(identities []age.Identity) []age.Recipient {
+	var recipients []age.Recipient
+	for _, identity := range identities {
+		if x, ok := identity.(*age.X25519Identity); ok {
+			recipients = append(recipients, x.Recipient())
+		}
+	}
+	return recipients
+}
+
+// Keyring decrypts .env files with the identities in IdentityFile. The
+// file is read on first use, so that plain .env files load without one.
+type Keyring struct {
+	IdentityFile string
+
+	loaded     bool
+	identities []age.Identity
+	err        error
+}
+
+// Identities returns the identities in the identity file
+func (k *Keyring) Identities() ([]age.Identity, error) {
+	if !k.loaded {
+		k.loaded = true
+		k.identities, k.err = ReadIdentities(k.IdentityFile)
+		if k.err != nil {
+			k.err = fmt.Errorf("reading identity file: %w", k.err)
+		}
+	}
+	return k.identities, k.err
+}
+
+// ReadDocument reads and parses a .env file, decrypting it in memory if it
+// is encrypted as a whole and decrypting its ENC[age:...] values. A file
+// or value that cannot be decrypted is reported in the document's
+// Diagnostics and left out, like a malformed line.
+func (k *Keyring) ReadDocument(path string) (*envfile.Document, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if IsEncryptedFile(path) {
+		plaintext, err := k.decryptFile(data)
+		if err != nil {
+			doc := envfile.ParseDocument(path, nil)
+			doc.Diagnostics = append(doc.Diagnostics, envfile.Diagnostic{
+				File:     path,
+				Line:     1,
+				Column:   1,
+				Severity: envfile.SeverityError,
+				Message:  "cannot decrypt: " + err.Error(),
+			})
+			return doc, nil
+		}
+		data = plaintext
+	}
+	doc := envfile.ParseDocument(path, data)
+	k.DecryptValues(doc)
+	return doc, nil
+}
+
+func (k *Keyring) decryptFile(data []byte) ([]byte, error) {
+	identities, err := k.Identities()
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(data, identities)
+}
+
+// DecryptValues replaces the ENC[age:...] values of doc with their
+// plaintext. Entries that cannot be decrypted are turned into invalid
+// nodes and reported in the document's Diagnostics.
+func (k *Keyring) DecryptValues(doc *envfile.Document) {
+	for _, n := range doc.Entries() {
+		if !IsEncryptedValue(n.RawValue) {
+			continue
+		}
+		identities, err := k.Identities()
+		var plaintext string
+		if err == nil {
+			plaintext, err = DecryptValue(n.RawValue, identities)
+		}
+		if err != nil {
+			n.Kind = envfile.InvalidNode
+			doc.Diagnostics = append(doc.Diagnostics, envfile.Diagnostic{
+				File:     doc.Name,
+				Line:     n.KeyPos.Line,
+				Column:   n.KeyPos.Column,
+				Severity: envfile.SeverityError,
+				Message:  fmt.Sprintf("cannot decrypt %s: %v", n.Key, err),
+			})
+			continue
+		}
+		n.SetLiteralValue(plaintext)
+	}
+}
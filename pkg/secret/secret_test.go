@@ -0,0 +1,164 @@
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/username/envtool/pkg/envfile"
+)
+
+func newIdentity(t *testing.T) *age.X25519Identity {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("Failed to generate identity: %v", err)
+	}
+	return identity
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	alice, bob, eve := newIdentity(t), newIdentity(t), newIdentity(t)
+	recipients := []age.Recipient{alice.Recipient(), bob.Recipient()}
+
+	data, err := Encrypt([]byte("TOKEN=secret\n"), recipients)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "-----BEGIN AGE ENCRYPTED FILE-----\n"))
+	assert.NotContains(t, string(data), "secret")
+
+	for _, identity := range []age.Identity{alice, bob} {
+		plaintext, err := Decrypt(data, []age.Identity{identity})
+		assert.NoError(t, err)
+		assert.Equal(t, "TOKEN=secret\n", string(plaintext))
+	}
+	_, err = Decrypt(data, []age.Identity{eve})
+	assert.Error(t, err)
+
+	_, err = Encrypt([]byte("x"), nil)
+	assert.EqualError(t, err, "no recipients to encrypt to")
+}
+
+func TestEncryptDecryptValue(t *testing.T) {
+	identity := newIdentity(t)
+
+	value, err := EncryptValue("p@ss 'word'\n", []age.Recipient{identity.Recipient()})
+	assert.NoError(t, err)
+	assert.True(t, IsEncryptedValue(value), value)
+	assert.NotContains(t, value, "word")
+
+	plaintext, err := DecryptValue(value, []age.Identity{identity})
+	assert.NoError(t, err)
+	assert.Equal(t, "p@ss 'word'\n", plaintext)
+
+	_, err = DecryptValue(value, []age.Identity{newIdentity(t)})
+	assert.Error(t, err)
+	_, err = DecryptValue("ENC[age:not base64!]", []age.Identity{identity})
+	assert.Error(t, err)
+	_, err = DecryptValue("plain", []age.Identity{identity})
+	assert.Error(t, err)
+
+	assert.False(t, IsEncryptedValue("ENC[age:]"))
+	assert.False(t, IsEncryptedValue("ENC[age:abc"))
+}
+
+func TestGenerateIdentity(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-secret-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	path := filepath.Join(tempDir, "envtool", "identity.txt")
+
+	identity, err := GenerateIdentity(path)
+	assert.NoError(t, err)
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	identities, err := ReadIdentities(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []age.Identity{identity}, identities)
+	assert.Equal(t, []age.Recipient{identity.Recipient()}, IdentityRecipients(identities))
+
+	// An existing identity is never overwritten
+	_, err = GenerateIdentity(path)
+	assert.True(t, os.IsExist(err), "expected an exists error, got %v", err)
+}
+
+func TestParseRecipients(t *testing.T) {
+	identity := newIdentity(t)
+	recipients, err := ParseRecipients([]string{" " + identity.Recipient().String() + "\n"})
+	assert.NoError(t, err)
+	assert.Equal(t, []age.Recipient{identity.Recipient()}, recipients)
+
+	_, err = ParseRecipients([]string{"age1nope"})
+	assert.Error(t, err)
+}
+
+func TestKeyring_ReadDocument(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "envtool-secret-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	identityFile := filepath.Join(tempDir, "identity.txt")
+	identity, err := GenerateIdentity(identityFile)
+	assert.NoError(t, err)
+	recipients := []age.Recipient{identity.Recipient()}
+
+	// Plain files need no identity
+	plain := filepath.Join(tempDir, ".env")
+	assert.NoError(t, ioutil.WriteFile(plain, []byte("A=1\n"), 0644))
+	k := &Keyring{IdentityFile: filepath.Join(tempDir, "missing.txt")}
+	doc, err := k.ReadDocument(plain)
+	assert.NoError(t, err)
+	assert.Empty(t, doc.Diagnostics)
+
+	// Whole files
+	data, err := Encrypt([]byte("TOKEN=$HOME\nB='x'\n"), recipients)
+	assert.NoError(t, err)
+	enc := filepath.Join(tempDir, ".env.enc")
+	assert.NoError(t, ioutil.WriteFile(enc, data, 0644))
+
+	k = &Keyring{IdentityFile: identityFile}
+	doc, err = k.ReadDocument(enc)
+	assert.NoError(t, err)
+	assert.Empty(t, doc.Diagnostics)
+	values, _ := doc.Values(func(string) (string, bool) { return "/home/me", true })
+	assert.Equal(t, map[string]string{"TOKEN": "/home/me", "B": "x"}, values)
+
+	// Single values are taken literally
+	secret, err := EncryptValue("$(not expanded)", recipients)
+	assert.NoError(t, err)
+	other, err := EncryptValue("x", []age.Recipient{newIdentity(t).Recipient()})
+	assert.NoError(t, err)
+	values2 := filepath.Join(tempDir, ".env.values")
+	assert.NoError(t, ioutil.WriteFile(values2, []byte("A=1\nSECRET="+secret+" # db\nQUOTED='"+secret+"'\nOTHER="+other+"\nB=2\n"), 0644))
+
+	doc, err = k.ReadDocument(values2)
+	assert.NoError(t, err)
+	if assert.Len(t, doc.Diagnostics, 1) {
+		assert.Equal(t, 4, doc.Diagnostics[0].Line)
+		assert.Equal(t, envfile.SeverityError, doc.Diagnostics[0].Severity)
+		assert.Contains(t, doc.Diagnostics[0].Message, "cannot decrypt OTHER")
+	}
+	values, _ = doc.Values(func(string) (string, bool) { return "", false })
+	assert.Equal(t, map[string]string{"A": "1", "SECRET": "$(not expanded)", "QUOTED": "$(not expanded)", "B": "2"}, values)
+	assert.Equal(t, 5, doc.Lookup("B").KeyPos.Line)
+
+	// A missing identity is reported, not fatal
+	k = &Keyring{IdentityFile: filepath.Join(tempDir, "missing.txt")}
+	doc, err = k.ReadDocument(enc)
+	assert.NoError(t, err)
+	if assert.Len(t, doc.Diagnostics, 1) {
+		assert.Contains(t, doc.Diagnostics[0].String(), enc+":1:1: error: cannot decrypt: reading identity file:")
+	}
+	assert.Empty(t, doc.Entries())
+
+	_, err = k.ReadDocument(filepath.Join(tempDir, "nope.enc"))
+	assert.True(t, os.IsNotExist(err))
+}
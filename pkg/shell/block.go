@@ -0,0 +1,170 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markers delimiting the hook block envtool manages in a shell
+// configuration file. Every supported shell uses '#' for comments.
+const (
+	BlockBegin = "# BEGIN envtool"
+	BlockEnd   = "# END envtool"
+)
+
+// Block is a hook block in a shell configuration file
+type Block struct {
+	// Header is the text following the begin marker on its line, such as
+	// the hook version and options
+	Header string
+	// Text is the block's source, from the begin marker line through the
+	// end marker line
+	Text string
+	// Line is the 1-based line number of the begin marker
+	Line int
+	// Legacy is set for a hook appended without marker lines by envtool
+	// versions before they were introduced. Text and Line then cover the
+	// hook itself.
+	Legacy bool
+
+	// start and end are byte offsets of the block, including the blank
+	// line HookBlock puts in front of it
+	start, end int
+}
+
+// HookBlock wraps a hook script in marker lines so that it can be found,
+// replaced and removed again. header, if not empty, is written after the
+// begin marker. The block is preceded by a blank line.
+func HookBlock(header, hook string) string {
+	begin := BlockBegin
+	if header != "" {
+		begin += " " + header
+	}
+	return "\n" + begin + "\n" + strings.Trim(hook, "\n") + "\n" + BlockEnd + "\n"
+}
+
+// FindHookBlocks returns the hook blocks in content: the marked ones and
+// any legacy hooks installed without markers, in the order they appear. A
+// begin marker without a matching end marker is an error.
+func FindHookBlocks(content string) ([]Block, error) {
+	lines := strings.SplitAfter(content, "\n")
+	var blocks []Block
+	offset := 0
+	// prevBlank is the offset of the previous line if it was blank, or -1
+	prevBlank := -1
+	for i := 0; i < len(lines); i++ {
+		if n, ok := matchLegacyHook(lines, i); ok {
+			b := Block{Line: i + 1, Legacy: true, start: offset}
+			if prevBlank >= 0 {
+				b.start = prevBlank
+			}
+			end := offset
+			for _, line := range lines[i : i+n] {
+				end += len(line)
+			}
+			b.Text = content[offset:end]
+			b.end = end
+			blocks = append(blocks, b)
+
+			offset = end
+			prevBlank = -1
+			i += n - 1
+			continue
+		}
+
+		header, ok := markerText(lines[i], BlockBegin)
+		if !ok {
+			if strings.TrimSpace(lines[i]) == "" {
+				prevBlank = offset
+			} else {
+				prevBlank = -1
+			}
+			offset += len(lines[i])
+			continue
+		}
+
+		b := Block{Header: header, Line: i + 1, start: offset}
+		if prevBlank >= 0 {
+			b.start = prevBlank
+		}
+		end := offset
+		j := i
+		for ; j < len(lines); j++ {
+			end += len(lines[j])
+			if _, ok := markerText(lines[j], BlockEnd); ok && j > i {
+				break
+			}
+		}
+		if j == len(lines) {
+			return nil, fmt.Errorf("line %d: %q has no matching %q", i+1, BlockBegin, BlockEnd)
+		}
+		b.Text = content[offset:end]
+		b.end = end
+		blocks = append(blocks, b)
+
+		offset = end
+		prevBlank = -1
+		i = j
+	}
+	return blocks, nil
+}
+
+// RemoveHookBlocks removes every hook block from content, marked or legacy,
+// together with the blank line in front of it. It returns the new content
+// and the number of blocks removed. A begin marker without a
+// matching end marker is an error, and content is returned unchanged.
+func RemoveHookBlocks(content string) (string, int, error) {
+	blocks, err := FindHookBlocks(content)
+	if err != nil {
+		return content, 0, err
+	}
+	return replaceBlocks(content, blocks, ""), len(blocks), nil
+}
+
+// ReplaceHookBlocks puts block in place of the first hook block in content,
+// marked or legacy, and removes any others. If content holds no block,
+// block is appended; an empty file gets the block without its leading
+// blank line. It returns the new content.
+func ReplaceHookBlocks(content, block string) (string, error) {
+	blocks, err := FindHookBlocks(content)
+	if err != nil {
+		return content, err
+	}
+	if content == "" {
+		return strings.TrimPrefix(block, "\n"), nil
+	}
+	if len(blocks) == 0 {
+		return content + block, nil
+	}
+	return replaceBlocks(content, blocks, block), nil
+}
+
+// replaceBlocks puts block in place of the first of blocks and drops the
+// rest
+func replaceBlocks(content string, blocks []Block, block string) string {
+	var b strings.Builder
+	last := 0
+	for i, found := range blocks {
+		b.WriteString(content[last:found.start])
+		if i == 0 {
+			b.WriteString(block)
+		}
+		last = found.end
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// markerText reports whether line is the given marker line and returns
+// the text after the marker. Text must be separated from the marker by a
+// space.
+func markerText(line, marker string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if line == marker {
+		return "", true
+	}
+	if strings.HasPrefix(line, marker+" ") {
+		return strings.TrimSpace(line[len(marker):]), true
+	}
+	return "", false
+}
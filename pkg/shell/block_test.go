@@ -0,0 +1,171 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHookBlock(t *testing.T) {
+	block := HookBlock("", "\n_envtool_hook() {\n  :\n}\n")
+	assert.Equal(t, "\n# BEGIN envtool\n_envtool_hook() {\n  :\n}\n# END envtool\n", block)
+}
+
+func TestHookBlock_Header(t *testing.T) {
+	block := HookBlock("v1 shell=bash", "hook")
+	assert.Equal(t, "\n# BEGIN envtool v1 shell=bash\nhook\n# END envtool\n", block)
+}
+
+// legacyFishHook is a fish hook as envtool init appended it before hook
+// blocks had markers
+const legacyFishHook = `
+function _envtool_hook --on-variable PWD --on-event fish_prompt
+  envtool env fish --env-file .env.local | source
+end
+`
+
+func TestFindHookBlocks(t *testing.T) {
+	content := "before\n" + HookBlock("v1 shell=zsh", "hook") + "after\n" + HookBlock("", "old")
+	blocks, err := FindHookBlocks(content)
+	assert.NoError(t, err)
+	if assert.Len(t, blocks, 2) {
+		assert.Equal(t, "v1 shell=zsh", blocks[0].Header)
+		assert.Equal(t, "# BEGIN envtool v1 shell=zsh\nhook\n# END envtool\n", blocks[0].Text)
+		assert.Equal(t, 3, blocks[0].Line)
+		assert.Equal(t, "", blocks[1].Header)
+		assert.Equal(t, 8, blocks[1].Line)
+	}
+}
+
+func TestFindHookBlocks_Legacy(t *testing.T) {
+	content := "alias ll='ls -l'\n" + legacyFishHook
+	blocks, err := FindHookBlocks(content)
+	assert.NoError(t, err)
+	if assert.Len(t, blocks, 1) {
+		assert.True(t, blocks[0].Legacy)
+		assert.Equal(t, 3, blocks[0].Line)
+		assert.Equal(t, strings.TrimPrefix(legacyFishHook, "\n"), blocks[0].Text)
+	}
+}
+
+func TestReplaceHookBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "Empty file",
+			content:  "",
+			expected: "# BEGIN envtool v2\nnew\n# END envtool\n",
+		},
+		{
+			name:     "Append to file without block",
+			content:  "before\n",
+			expected: "before\n" + HookBlock("v2", "new"),
+		},
+		{
+			name:     "Replace old block in place",
+			content:  "before\n" + HookBlock("v1", "old") + "after\n",
+			expected: "before\n" + HookBlock("v2", "new") + "after\n",
+		},
+		{
+			name:     "Drop duplicate blocks",
+			content:  HookBlock("", "one") + "middle\n" + HookBlock("v1", "two"),
+			expected: HookBlock("v2", "new") + "middle\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ReplaceHookBlocks(tt.content, HookBlock("v2", "new"))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestRemoveHookBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		removed  int
+	}{
+		{
+			name:     "No block",
+			content:  "alias ll='ls -l'\n",
+			expected: "alias ll='ls -l'\n",
+		},
+		{
+			name:     "Appended block",
+			content:  "alias ll='ls -l'\n" + HookBlock("", "hook"),
+			expected: "alias ll='ls -l'\n",
+			removed:  1,
+		},
+		{
+			name:     "Block between other lines",
+			content:  "before\n" + HookBlock("", "hook") + "after\n",
+			expected: "before\nafter\n",
+			removed:  1,
+		},
+		{
+			name:     "Several blocks",
+			content:  HookBlock("", "one") + "middle\n" + HookBlock("", "two"),
+			expected: "middle\n",
+			removed:  2,
+		},
+		{
+			name:     "Block without trailing newline",
+			content:  "before\n# BEGIN envtool\nhook\n# END envtool",
+			expected: "before\n",
+			removed:  1,
+		},
+		{
+			name:     "Marker with details",
+			content:  "before\n# BEGIN envtool v1\nhook\n# END envtool\n",
+			expected: "before\n",
+			removed:  1,
+		},
+		{
+			name:     "Legacy fish hook",
+			content:  "before\n" + legacyFishHook + "after\n",
+			expected: "before\nafter\n",
+			removed:  1,
+		},
+		{
+			name:     "Legacy hook next to a block",
+			content:  legacyFishHook + HookBlock("v1", "hook"),
+			expected: "",
+			removed:  2,
+		},
+		{
+			name:     "Edited legacy hook is kept",
+			content:  "function _envtool_hook --on-variable PWD --on-event fish_prompt\n  mytool | source\nend\n",
+			expected: "function _envtool_hook --on-variable PWD --on-event fish_prompt\n  mytool | source\nend\n",
+		},
+		{
+			name:     "Similar comment is not a marker",
+			content:  "# BEGIN envtoolbox\n",
+			expected: "# BEGIN envtoolbox\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, removed, err := RemoveHookBlocks(tt.content)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+			assert.Equal(t, tt.removed, removed)
+		})
+	}
+}
+
+func TestRemoveHookBlocks_Unterminated(t *testing.T) {
+	content := "before\n# BEGIN envtool\nhook\n"
+	result, removed, err := RemoveHookBlocks(content)
+	assert.EqualError(t, err, `line 2: "# BEGIN envtool" has no matching "# END envtool"`)
+	assert.Equal(t, content, result)
+	assert.Equal(t, 0, removed)
+}
@@ -0,0 +1,96 @@
+package shell
+
+import "strings"
+
+// legacyHooks are the hooks envtool init appended before it wrapped them in
+// marker lines. The %s in each stands for the --env-file arguments the hook
+// was built with. They must stay as they were released, so that the hooks
+// can still be found after the current templates change.
+var legacyHooks = []string{
+	// bash
+	`_envtool_hook() {
+  local previous_exit_status=$?;
+  trap -- '' SIGINT;
+  eval "$(envtool env bash%s)";
+  trap - SIGINT;
+  return $previous_exit_status;
+};
+if ! [[ "${PROMPT_COMMAND:-}" =~ _envtool_hook ]]; then
+  PROMPT_COMMAND="_envtool_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi`,
+	// zsh
+	`_envtool_hook() {
+  trap -- '' SIGINT;
+  eval "$(envtool env zsh%s)";
+  trap - SIGINT;
+}
+typeset -ag precmd_functions;
+if [[ -z "${precmd_functions[(r)_envtool_hook]+1}" ]]; then
+  precmd_functions=( _envtool_hook ${precmd_functions[@]} )
+fi
+typeset -ag chpwd_functions;
+if [[ -z "${chpwd_functions[(r)_envtool_hook]+1}" ]]; then
+  chpwd_functions=( _envtool_hook ${chpwd_functions[@]} )
+fi`,
+	// fish
+	`function _envtool_hook --on-variable PWD --on-event fish_prompt
+  envtool env fish%s | source
+end`,
+	// PowerShell
+	`function global:_envtool_hook {
+  $output = envtool env pwsh%s | Out-String
+  if ($output.Trim()) { Invoke-Expression $output }
+}
+if ($global:_envtool_hooked -ne 1) {
+  $global:_envtool_hooked = 1
+  $global:_envtool_prompt_old = $function:prompt
+  function global:prompt {
+    $null = _envtool_hook
+    if ($null -ne $global:_envtool_prompt_old) { & $global:_envtool_prompt_old }
+  }
+}`,
+	// nushell
+	`$env.config = ($env.config | upsert hooks.pre_prompt (
+  ($env.config.hooks?.pre_prompt? | default []) | append {||
+    let lines = (^envtool env nu%s | lines)
+    let hide = ($lines | where {|l| $l | str starts-with 'hide-env -i ' } | each {|l| '[' + ($l | str substring 12..) + ']' | from nuon } | flatten)
+    let load = ($lines | where {|l| $l | str starts-with 'load-env ' } | each {|l| $l | str substring 9.. | from nuon } | reduce --fold {} {|it, acc| $acc | merge $it })
+    hide-env -i ...$hide
+    load-env $load
+  }
+))`,
+}
+
+// matchLegacyHook reports whether one of the legacy hooks starts at
+// lines[i], and returns the number of lines it spans
+func matchLegacyHook(lines []string, i int) (int, bool) {
+	for _, hook := range legacyHooks {
+		want := strings.Split(hook, "\n")
+		if i+len(want) > len(lines) {
+			continue
+		}
+		matched := true
+		for k, pattern := range want {
+			if !matchLegacyLine(strings.TrimRight(lines[i+k], "\r\n"), pattern) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return len(want), true
+		}
+	}
+	return 0, false
+}
+
+// matchLegacyLine matches a line against a line of a legacy hook, where %s
+// stands for any text
+func matchLegacyLine(line, pattern string) bool {
+	i := strings.Index(pattern, "%s")
+	if i < 0 {
+		return line == pattern
+	}
+	prefix, suffix := pattern[:i], pattern[i+len("%s"):]
+	return len(line) >= len(prefix)+len(suffix) &&
+		strings.HasPrefix(line, prefix) && strings.HasSuffix(line, suffix)
+}
@@ -1,44 +1,50 @@
 package shell
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
+	"time"
+
+	"github.com/username/envtool/pkg/atomicfile"
 )
 
+// BackupSuffix ends the name of backups made by BackupFile
+const BackupSuffix = ".envtool.bak"
+
 // FileManager handles reading and writing shell configuration files
 type FileManager interface {
 	AppendToFile(path, content string) error
 	ReadFile(path string) (string, error)
 	FileExists(path string) (bool, error)
 	WriteFile(path, content string) error
+	// BackupFile copies a file to a timestamped backup next to it and
+	// returns the backup's path
+	BackupFile(path string) (string, error)
 }
 
-// DefaultFileManager implements the FileManager interface
-type DefaultFileManager struct{}
+// DefaultFileManager implements the FileManager interface. Writes are
+// atomic: content goes to a temporary file in the target's directory,
+// which is synced and renamed over the target, so a failure never leaves
+// a truncated file behind. Existing files keep their mode and owner, and
+// symlinks are followed so that their target is replaced.
+type DefaultFileManager struct {
+	// Now returns the time used in backup names; nil means time.Now
+	Now func() time.Time
+}
 
 // AppendToFile appends content to a file
 func (fm *DefaultFileManager) AppendToFile(path, content string) error {
-	// Check if the content is already in the file to avoid duplicates
-	exists, err := fm.ContainsContent(path, content)
-	if err != nil {
+	fileContent, err := fm.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	if exists {
-		// Content already exists, no need to append
+	// Check if the content is already in the file to avoid duplicates
+	if strings.Contains(fileContent, content) {
 		return nil
 	}
-
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	
-	if _, err = f.WriteString(content); err != nil {
-		return err
-	}
-	return nil
+	return atomicfile.WriteFile(path, []byte(fileContent+content), 0644)
 }
 
 // ReadFile reads content from a file
@@ -75,7 +81,42 @@ func (fm *DefaultFileManager) FileExists(path string) (bool, error) {
 	return false, err
 }
 
-// WriteFile writes content to a file
+// WriteFile atomically replaces the content of a file, creating it with
+// mode 0644 if it does not exist
 func (fm *DefaultFileManager) WriteFile(path, content string) error {
-	return ioutil.WriteFile(path, []byte(content), 0644)
-}
\ No newline at end of file
+	return atomicfile.WriteFile(path, []byte(content), 0644)
+}
+
+// BackupFile copies a file to PATH.<timestamp>.envtool.bak, keeping its
+// mode and owner. A numeric suffix is added if a backup with the same
+// timestamp already exists.
+func (fm *DefaultFileManager) BackupFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now
+	if fm.Now != nil {
+		now = fm.Now
+	}
+	base := path + "." + now().Format("20060102-150405")
+	backupPath := base + BackupSuffix
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(backupPath); os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		backupPath = fmt.Sprintf("%s.%d%s", base, i, BackupSuffix)
+	}
+
+	if err := atomicfile.WriteFileLike(backupPath, data, info); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -133,4 +134,85 @@ func TestContainsContent(t *testing.T) {
 	contains, err = fileManager.ContainsContent(filepath.Join(tempDir, "nonexistent"), "content")
 	assert.NoError(t, err)
 	assert.False(t, contains)
-}
\ No newline at end of file
+}
+func TestWriteFile_KeepsModeAndSymlink(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "shell-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	target := filepath.Join(tempDir, "zshrc")
+	link := filepath.Join(tempDir, "link")
+	assert.NoError(t, ioutil.WriteFile(target, []byte("old"), 0600))
+	assert.NoError(t, os.Symlink(target, link))
+
+	fileManager := &DefaultFileManager{}
+	assert.NoError(t, fileManager.WriteFile(link, "new"))
+
+	// The symlink still points to the target, which has the new content
+	dest, err := os.Readlink(link)
+	assert.NoError(t, err)
+	assert.Equal(t, target, dest)
+	content, err := ioutil.ReadFile(target)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+
+	info, err := os.Stat(target)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// No temporary files are left behind
+	entries, err := ioutil.ReadDir(tempDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestWriteFile_NewFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "shell-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	testFile := filepath.Join(tempDir, "bashrc")
+	fileManager := &DefaultFileManager{}
+	assert.NoError(t, fileManager.WriteFile(testFile, "content"))
+
+	info, err := os.Stat(testFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
+
+func TestBackupFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "shell-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	testFile := filepath.Join(tempDir, "bashrc")
+	assert.NoError(t, ioutil.WriteFile(testFile, []byte("content"), 0640))
+
+	fileManager := &DefaultFileManager{
+		Now: func() time.Time { return time.Date(2024, 3, 1, 12, 30, 45, 0, time.UTC) },
+	}
+	backupPath, err := fileManager.BackupFile(testFile)
+	assert.NoError(t, err)
+	assert.Equal(t, testFile+".20240301-123045.envtool.bak", backupPath)
+
+	content, err := ioutil.ReadFile(backupPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+	info, err := os.Stat(backupPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	// A second backup in the same second gets its own name
+	backupPath, err = fileManager.BackupFile(testFile)
+	assert.NoError(t, err)
+	assert.Equal(t, testFile+".20240301-123045.1.envtool.bak", backupPath)
+
+	_, err = fileManager.BackupFile(filepath.Join(tempDir, "missing"))
+	assert.True(t, os.IsNotExist(err))
+}
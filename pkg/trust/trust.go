@@ -0,0 +1,178 @@
+// Package trust records which .env files the user has allowed envtool to
+// load. A file is trusted for the exact content it had when it was
+// allowed; any change to it has to be allowed again.
+package trust
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/username/envtool/pkg/atomicfile"
+)
+
+// Status is the trust state of a file
+type Status int
+
+const (
+	// Untrusted files were never allowed, or were denied
+	Untrusted Status = iota
+	// Changed files were allowed, but their content changed since
+	Changed
+	// Trusted files were allowed with their current content
+	Trusted
+)
+
+func (s Status) String() string {
+	switch s {
+	case Trusted:
+		return "trusted"
+	case Changed:
+		return "changed"
+	}
+	return "untrusted"
+}
+
+// Entry is an allowed file
+type Entry struct {
+	// Path is the file's absolute path
+	Path string `json:"path"`
+	// Hash is the SHA-256 of the file's content when it was allowed
+	Hash string `json:"hash"`
+	// Allowed is when the file was allowed
+	Allowed time.Time `json:"allowed"`
+}
+
+// Store is a trust database kept in a JSON file
+type Store struct {
+	// Path is the database file
+	Path    string
+	entries map[string]Entry
+}
+
+// DefaultPath returns $XDG_DATA_HOME/envtool/trust.json, falling back to
+// ~/.local/share/envtool/trust.json
+func DefaultPath() (string, error) {
+	dataDir := os.Getenv("XDG_DATA_HOME")
+	if dataDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil || home == "" {
+			return "", fmt.Errorf("cannot locate the trust database: HOME is not set")
+		}
+		dataDir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataDir, "envtool", "trust.json"), nil
+}
+
+// Load reads the trust database at path. A missing database is empty.
+func Load(path string) (*Store, error) {
+	s := &Store{Path: path, entries: map[string]Entry{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, e := range entries {
+		s.entries[e.Path] = e
+	}
+	return s, nil
+}
+
+// Save writes the database atomically, readable only by the user
+func (s *Store) Save() error {
+	data, err := json.MarshalIndent(s.Entries(), "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(s.Path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(s.Path, append(data, '\n'), 0600)
+}
+
+// Entries returns the allowed files sorted by path
+func (s *Store) Entries() []Entry {
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
+}
+
+// Allow trusts the current content of the file at path
+func (s *Store) Allow(path string) (Entry, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	hash, err := HashFile(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	e := Entry{Path: path, Hash: hash, Allowed: time.Now().UTC().Truncate(time.Second)}
+	s.entries[path] = e
+	return e, nil
+}
+
+// Deny revokes trust in the file at path and reports whether it was
+// allowed before. The file need not exist.
+func (s *Store) Deny(path string) (bool, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	_, ok := s.entries[path]
+	delete(s.entries, path)
+	return ok, nil
+}
+
+// Check returns the trust status of the file at path
+func (s *Store) Check(path string) (Status, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return Untrusted, err
+	}
+	e, ok := s.entries[path]
+	if !ok {
+		return Untrusted, nil
+	}
+	hash, err := HashFile(path)
+	if err != nil {
+		return Untrusted, err
+	}
+	if hash != e.Hash {
+		return Changed, nil
+	}
+	return Trusted, nil
+}
+
+// HashFile returns the hex SHA-256 of a file's content
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
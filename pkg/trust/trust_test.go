@@ -0,0 +1,91 @@
+package trust
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "trust-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	dbPath := filepath.Join(tempDir, "data", "trust.json")
+	envPath := filepath.Join(tempDir, ".env")
+	assert.NoError(t, ioutil.WriteFile(envPath, []byte("A=1\n"), 0644))
+
+	// A missing database is empty
+	store, err := Load(dbPath)
+	assert.NoError(t, err)
+	assert.Empty(t, store.Entries())
+	status, err := store.Check(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, Untrusted, status)
+
+	entry, err := store.Allow(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, envPath, entry.Path)
+	assert.NoError(t, store.Save())
+
+	info, err := os.Stat(dbPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Trust survives a reload
+	store, err = Load(dbPath)
+	assert.NoError(t, err)
+	status, err = store.Check(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, Trusted, status)
+
+	// Any change to the content revokes it
+	assert.NoError(t, ioutil.WriteFile(envPath, []byte("A=2\n"), 0644))
+	status, err = store.Check(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, Changed, status)
+
+	allowed, err := store.Deny(envPath)
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+	allowed, err = store.Deny(envPath)
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	status, err = store.Check(envPath)
+	assert.NoError(t, err)
+	assert.Equal(t, Untrusted, status)
+}
+
+func TestLoad_Malformed(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "trust-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	dbPath := filepath.Join(tempDir, "trust.json")
+	assert.NoError(t, ioutil.WriteFile(dbPath, []byte("{"), 0600))
+	_, err = Load(dbPath)
+	assert.Error(t, err)
+}
+
+func TestDefaultPath(t *testing.T) {
+	origDataHome, hadDataHome := os.LookupEnv("XDG_DATA_HOME")
+	defer func() {
+		if hadDataHome {
+			os.Setenv("XDG_DATA_HOME", origDataHome)
+		} else {
+			os.Unsetenv("XDG_DATA_HOME")
+		}
+	}()
+
+	os.Setenv("XDG_DATA_HOME", "/data")
+	path, err := DefaultPath()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("/data", "envtool", "trust.json"), path)
+}
@@ -56,6 +56,13 @@ func TestFullWorkflow(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Contains(t, string(zshContent), "_envtool_hook")
 	
+	// Trust the .env file, using a trust database of our own
+	os.Setenv("ENVTOOL_TRUST_DB", filepath.Join(tempDir, "trust.json"))
+	defer os.Unsetenv("ENVTOOL_TRUST_DB")
+	allowCmd := exec.Command(binaryPath, "allow", envFilePath)
+	output, err = allowCmd.CombinedOutput()
+	assert.NoError(t, err, "Allow command failed: %s", output)
+	
 	// Run env command with custom env file
 	envCmd := exec.Command(binaryPath, "env", "bash", "--env-file", envFilePath)
 	output, err = envCmd.CombinedOutput()
@@ -70,6 +77,8 @@ func TestFullWorkflow(t *testing.T) {
 	// Simulate a change in the .env file
 	err = ioutil.WriteFile(envFilePath, []byte("TEST_VAR=updated_value\nNEW_VAR=new_value"), 0644)
 	assert.NoError(t, err)
+	output, err = exec.Command(binaryPath, "allow", envFilePath).CombinedOutput()
+	assert.NoError(t, err, "Allow command failed: %s", output)
 	
 	// Set environment variable to simulate previous run
 	os.Setenv("ENVTOOL_MANAGED_ENV_VARS", "TEST_VAR,ANOTHER_VAR")